@@ -3,6 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"os/user"
 	"path"
 
 	log "github.com/sirupsen/logrus"
@@ -19,45 +22,94 @@ import (
 var (
 	membersFile string
 	secretsFile string
+	jobsFile    string
 	botName     string
 	botChannel  string
+	console     bool
+	consoleUser string
 )
 
 func init() {
-	logging.Setup()
 	exePath := files.FindExeDir()
 	flag.StringVar(&membersFile, "members", path.Join(exePath, "members.yml"), "Location of the members file")
 	flag.StringVar(&secretsFile, "secrets", path.Join(exePath, "secrets.yml"), "Location of the secrets file")
+	flag.StringVar(&jobsFile, "jobs", path.Join(exePath, "jobs.yml"), "Location of the jobs file")
 	flag.StringVar(&botChannel, "channel", "lab-bot-channel", "Name of the bot channel")
+	flag.BoolVar(&console, "console", false, "Run the bot in the terminal without connecting to Slack")
+	flag.StringVar(&consoleUser, "console-user", currentUser(), "Name of the user typing in console mode")
 }
 
 func main() {
+	flag.Parse()
+	if console {
+		logging.SetConsole(io.Discard)
+	}
+	logging.Setup()
+
 	fmt.Println("::: Lab Bot :::")
 	log.Info("Program Starting...")
-	flag.Parse()
 
 	log.Info("Checking config files.")
-	files.CheckFile(membersFile)
-	files.CheckFile(secretsFile)
+	if !console {
+		files.CheckFile(membersFile)
+		files.CheckFile(secretsFile)
+	}
+	files.CheckFile(jobsFile)
 
 	log.Info("Loading config files.")
-	config.ParseMembers(membersFile)
-	config.ParseSecrets(secretsFile)
-	slack.CheckSlackSecrets()
+	if !console || files.FileExists(membersFile) {
+		config.ParseMembers(membersFile)
+	}
+	if !console || files.FileExists(secretsFile) {
+		config.ParseSecrets(secretsFile)
+	}
+	config.ParseJobs(jobsFile)
 
 	db.Open()
 	defer db.Close()
 
-	slack.CreatePackageClient(botChannel)
-	go slack.EventProcessor()
-	go slack.RunSocketMode()
+	var messenger slack.Messenger
+	var consoleMessenger *slack.ConsoleMessenger
+	if console {
+		consoleMessenger = slack.NewConsoleMessenger(os.Stdout, botChannel)
+		messenger = consoleMessenger
+	} else {
+		slack.CheckSlackSecrets()
+		slack.CreatePackageClient(botChannel)
+		go slack.EventProcessor()
+		go slack.RunSocketMode()
+		messenger = slack.PackageMessenger()
+	}
 
 	scheduleTracker := scheduling.CreateScheduleTracker()
 	go scheduleTracker.Reciever()
 
-	jobHandler := jobs.CreateHandler()
+	jobHandler, err := jobs.CreateHandler(messenger, config.Jobs)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid jobs file.")
+	}
 	jobHandler.InitJobs()
 	go jobHandler.CommandReceiver()
 
-	CatchOSSignals()
+	quit := make(chan struct{})
+	if console {
+		fmt.Println("Type commands as you would after @lab-bot. Ctrl-D quits.")
+		go func() {
+			err := slack.RunConsole(consoleMessenger, os.Stdin, consoleUser)
+			if err != nil {
+				log.WithError(err).Error("Cannot read from the console")
+			}
+			close(quit)
+		}()
+	}
+
+	CatchOSSignals(quit)
+}
+
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil || u.Username == "" {
+		return "console"
+	}
+	return u.Username
 }
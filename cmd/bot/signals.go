@@ -6,14 +6,19 @@ import (
 	"os/signal"
 )
 
-func CatchOSSignals() {
+func CatchOSSignals(quit <-chan struct{}) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 
-	for sig := range c {
-		if sig == os.Interrupt {
-			fmt.Println("")
-			break
+	for {
+		select {
+		case sig := <-c:
+			if sig == os.Interrupt {
+				fmt.Println("")
+				return
+			}
+		case <-quit:
+			return
 		}
 	}
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"github.com/vishhvaan/lab-bot/drivers/fakes"
+)
+
+// fakekasa imitates a Kasa HS110 plug on the local network, so the kasa
+// driver can be tried out (for instance with the bot's -console mode)
+// without a real plug.
+
+var (
+	address string
+	watts   float64
+)
+
+func main() {
+	flag.StringVar(&address, "listen", "127.0.0.1:9999", "Address to listen on")
+	flag.Float64Var(&watts, "watts", 850, "Power reported by the energy meter while the relay is on")
+	flag.Parse()
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("fake kasa plug listening on %s", ln.Addr())
+
+	p := &fakes.KasaPlug{Watts: watts, Log: log.Default()}
+	log.Fatal(p.Serve(ln))
+}
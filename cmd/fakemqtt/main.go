@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/vishhvaan/lab-bot/drivers/fakes"
+)
+
+// fakemqtt is a tiny MQTT 3.1.1 broker with a Tasmota-style plug attached,
+// so the mqtt driver can be tried out without a real broker or device. The
+// plug listens on cmnd/<plug>/POWER and reports on stat/<plug>/POWER.
+// Typing on, off or toggle on stdin presses the plug's button, which changes
+// its state behind the bot's back.
+
+var (
+	address  string
+	plugName string
+)
+
+func main() {
+	flag.StringVar(&address, "listen", "127.0.0.1:1883", "Address to listen on")
+	flag.StringVar(&plugName, "plug", "coffee", "Name of the simulated plug in its topics")
+	flag.Parse()
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("fake mqtt broker listening on %s, plug topics cmnd/%s/POWER and stat/%s/POWER", ln.Addr(), plugName, plugName)
+
+	b := fakes.NewMQTTBroker(plugName)
+	b.Log = log.Default()
+	go buttons(b)
+	log.Fatal(b.Serve(ln))
+}
+
+func buttons(b *fakes.MQTTBroker) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if err := b.PressButton(strings.ToLower(strings.TrimSpace(scanner.Text()))); err != nil {
+			log.Print("type on, off or toggle to press the plug's button")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/vishhvaan/lab-bot/drivers/fakes"
+)
+
+// fakeserial imitates an instrument on a serial port, so the serial driver
+// can be tried out without one. It opens a pseudo-terminal and prints the
+// device to put in the controller settings. Commands end with \r or \n and
+// are answered with OK, or ON/OFF for the status command. Typing on or off on
+// stdin flips the instrument's switch behind the bot's back.
+
+func main() {
+	inst := &fakes.Instrument{Log: log.Default()}
+	flag.StringVar(&inst.OnCommand, "on", "ON", "Command that turns the instrument on")
+	flag.StringVar(&inst.OffCommand, "off", "OFF", "Command that turns the instrument off")
+	flag.StringVar(&inst.StatusCommand, "status", "STATUS?", "Command that asks for the state")
+	flag.Parse()
+
+	master, device, err := fakes.OpenPTY()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer master.Close()
+	log.Printf("fake instrument on %s", device)
+
+	go switches(inst)
+	log.Fatal(inst.Serve(master))
+}
+
+func switches(inst *fakes.Instrument) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "on":
+			inst.Set(true)
+		case "off":
+			inst.Set(false)
+		default:
+			log.Print("type on or off to flip the instrument's switch")
+		}
+	}
+}
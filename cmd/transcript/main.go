@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/harness"
+	"github.com/vishhvaan/lab-bot/logging"
+)
+
+var (
+	jobsFile string
+	update   bool
+	verbose  bool
+)
+
+func init() {
+	flag.StringVar(&jobsFile, "jobs", "", "Jobs file for the transcripts (default jobs.yml next to each transcript)")
+	flag.BoolVar(&update, "update", false, "Rewrite failing transcripts with the actual bot output")
+	flag.BoolVar(&verbose, "v", false, "Print the bot logs while running")
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: transcript [flags] <file or directory>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if !verbose {
+		logging.SetConsole(io.Discard)
+		log.SetOutput(io.Discard)
+	}
+
+	paths, err := transcriptPaths(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		result, err := harness.Run(path, jobsFile, update)
+		if err != nil {
+			fmt.Printf("ERROR %s\n%v\n", path, err)
+			failed++
+			continue
+		}
+		if result.Passed() {
+			fmt.Printf("ok    %s\n", path)
+			continue
+		}
+		failed++
+		if update {
+			fmt.Printf("UPDATED %s\n", path)
+		} else {
+			fmt.Printf("FAIL  %s\n", path)
+		}
+		for _, f := range result.Failures {
+			fmt.Print(f.String())
+		}
+	}
+
+	if failed > 0 && !update {
+		os.Exit(1)
+	}
+}
+
+func transcriptPaths(args []string) (paths []string, err error) {
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+var Jobs JobsConfig
+
+type JobsConfig struct {
+	Jobs        []JobConfig        `yaml:"jobs"`
+	Controllers []ControllerConfig `yaml:"controllers"`
+	Groups      []GroupConfig      `yaml:"groups"`
+	Scenes      []SceneConfig      `yaml:"scenes"`
+	Interlocks  []InterlockConfig  `yaml:"interlocks"`
+}
+
+type JobConfig struct {
+	Type     string    `yaml:"type"`
+	Name     string    `yaml:"name"`
+	Keyword  string    `yaml:"keyword"`
+	Aliases  []string  `yaml:"aliases"`
+	Desc     string    `yaml:"desc"`
+	Enabled  *bool     `yaml:"enabled"`
+	Channel  string    `yaml:"channel"`
+	Cron     string    `yaml:"cron"`
+	Options  yaml.Node `yaml:"options"`
+	Holidays *bool     `yaml:"respect_holidays"`
+
+	Permissions map[string][]string `yaml:"permissions"`
+	Approval    ApprovalConfig      `yaml:"approval"`
+}
+
+type ControllerConfig struct {
+	Name         string         `yaml:"name"`
+	Keyword      string         `yaml:"keyword"`
+	Aliases      []string       `yaml:"aliases"`
+	Machine      string         `yaml:"machine"`
+	Enabled      *bool          `yaml:"enabled"`
+	Driver       string         `yaml:"driver"`
+	Settings     yaml.Node      `yaml:"settings"`
+	Poll         *time.Duration `yaml:"poll"`
+	MaxOn        time.Duration  `yaml:"max_on"`
+	MaxOnWarning time.Duration  `yaml:"max_on_warning"`
+
+	Allow       []string            `yaml:"allow"`
+	Permissions map[string][]string `yaml:"permissions"`
+	Approval    ApprovalConfig      `yaml:"approval"`
+}
+
+// GroupConfig names controllers that are switched and scheduled together.
+type GroupConfig struct {
+	Name        string   `yaml:"name"`
+	Keyword     string   `yaml:"keyword"`
+	Aliases     []string `yaml:"aliases"`
+	Controllers []string `yaml:"controllers"`
+
+	Allow       []string            `yaml:"allow"`
+	Permissions map[string][]string `yaml:"permissions"`
+	Approval    ApprovalConfig      `yaml:"approval"`
+}
+
+// ApprovalConfig lists the subcommands of a job that only run once a second
+// member approves them, and how long a request waits for that.
+type ApprovalConfig struct {
+	Commands []string      `yaml:"commands"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// SceneConfig sets several controllers to given states with one command.
+type SceneConfig struct {
+	Name   string      `yaml:"name"`
+	Desc   string      `yaml:"desc"`
+	States SceneStates `yaml:"states"`
+}
+
+type SceneState struct {
+	Controller string
+	State      string
+}
+
+// SceneStates keeps the states of a scene in the order they are written, which
+// is the order the controllers are switched in.
+type SceneStates []SceneState
+
+func (ss *SceneStates) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return errors.New("states should map controller keywords to on or off")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		*ss = append(*ss, SceneState{
+			Controller: node.Content[i].Value,
+			State:      node.Content[i+1].Value,
+		})
+	}
+	return nil
+}
+
+// InterlockConfig keeps a controller from running unless the controllers it
+// requires are on, or while the ones it conflicts with are on. With cascade,
+// turning off a required controller turns this one off first instead of
+// being refused.
+type InterlockConfig struct {
+	Controller string   `yaml:"controller"`
+	Requires   []string `yaml:"requires"`
+	Conflicts  []string `yaml:"conflicts"`
+	Cascade    bool     `yaml:"cascade"`
+}
+
+func (cc ControllerConfig) IsEnabled() bool {
+	return cc.Enabled == nil || *cc.Enabled
+}
+
+// IsEnabled reports whether the job should be loaded; jobs are enabled
+// unless the config says otherwise.
+func (jc JobConfig) IsEnabled() bool {
+	return jc.Enabled == nil || *jc.Enabled
+}
+
+// RespectsHolidays reports whether the job's cron runs are skipped on
+// holidays, which they are unless the config says otherwise.
+func (jc JobConfig) RespectsHolidays() bool {
+	return jc.Holidays == nil || *jc.Holidays
+}
+
+// DecodeOptions fills options from the job's options section, leaving the
+// existing values in place for anything the section doesn't set.
+func (jc JobConfig) DecodeOptions(options any) error {
+	if jc.Options.Kind == 0 {
+		return nil
+	}
+	return jc.Options.Decode(options)
+}
+
+func LoadJobs(jobsFile string) (jobs JobsConfig, err error) {
+	yamlJobs, err := ioutil.ReadFile(jobsFile)
+	if err != nil {
+		return jobs, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlJobs))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&jobs)
+	if err == io.EOF {
+		err = nil
+	}
+	return jobs, err
+}
+
+func ParseJobs(jobsFile string) {
+	var err error
+	Jobs, err = LoadJobs(jobsFile)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("Cannot parse jobs file.")
+	}
+}
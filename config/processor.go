@@ -9,6 +9,8 @@ import (
 
 var Secrets map[string]string
 
+var secretsFile string
+
 var Members map[string]Member
 
 type Member struct {
@@ -20,15 +22,47 @@ type Member struct {
 	Roles     []string `yaml:"roles"`
 }
 
-func ParseMembers(membersFile string) {
+// MemberByID finds the member with a Slack user ID.
+func MemberByID(userID string) (member Member, ok bool) {
+	_, member, ok = FindMember(userID)
+	return member, ok
+}
+
+// FindMember finds the member with a Slack user ID, along with the name
+// they are listed under in the members file.
+func FindMember(userID string) (name string, member Member, ok bool) {
+	if userID == "" {
+		return "", member, false
+	}
+	for n, m := range Members {
+		if m.UserID == userID {
+			return n, m, true
+		}
+	}
+	return "", member, false
+}
+
+func (m Member) HasRole(role string) bool {
+	for _, r := range m.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func LoadMembers(membersFile string) (members map[string]Member, err error) {
 	yamlMembers, err := ioutil.ReadFile(membersFile)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Fatal("Cannot read members file.")
+		return nil, err
 	}
+	err = yaml.Unmarshal(yamlMembers, &members)
+	return members, err
+}
 
-	err = yaml.Unmarshal(yamlMembers, &Members)
+func ParseMembers(membersFile string) {
+	var err error
+	Members, err = LoadMembers(membersFile)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
@@ -36,8 +70,8 @@ func ParseMembers(membersFile string) {
 	}
 }
 
-func ParseSecrets(secretsFile string) {
-	yamlSecrets, err := ioutil.ReadFile(secretsFile)
+func ParseSecrets(file string) {
+	yamlSecrets, err := ioutil.ReadFile(file)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
@@ -50,4 +84,23 @@ func ParseSecrets(secretsFile string) {
 			"error": err,
 		}).Fatal("Cannot parse secrets file.")
 	}
+	secretsFile = file
+}
+
+// ReloadSecrets reads the secrets file again, so a fixed key can be used
+// without a restart. The old secrets are kept if it can't be read.
+func ReloadSecrets() error {
+	if secretsFile == "" {
+		return nil
+	}
+	yamlSecrets, err := ioutil.ReadFile(secretsFile)
+	if err != nil {
+		return err
+	}
+	var secrets map[string]string
+	if err = yaml.Unmarshal(yamlSecrets, &secrets); err != nil {
+		return err
+	}
+	Secrets = secrets
+	return nil
 }
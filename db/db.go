@@ -20,9 +20,12 @@ type database struct {
 var botDB database
 
 func Open() {
-	botDB.logger = logging.CreateNewLogger("database", "database")
 	exePath := files.FindExeDir()
-	dbPath := path.Join(exePath, dbFile)
+	OpenAt(path.Join(exePath, dbFile))
+}
+
+func OpenAt(dbPath string) {
+	botDB.logger = logging.CreateNewLogger("database", "database")
 
 	var err error
 	botDB.db, err = bolt.Open(dbPath, 0600, nil)
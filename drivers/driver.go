@@ -0,0 +1,107 @@
+package drivers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vishhvaan/lab-bot/functions"
+)
+
+// Driver switches a lab device on and off. Controllers call Init once when
+// they load and On or Off for every power command.
+type Driver interface {
+	Init() error
+	On() error
+	Off() error
+}
+
+// StatusReader is implemented by drivers that can ask the device whether it
+// is actually "on" or "off".
+type StatusReader interface {
+	Status() (state string, err error)
+}
+
+// PowerMeter is implemented by drivers that can measure the power drawn by
+// the device, in watts.
+type PowerMeter interface {
+	Power() (watts float64, err error)
+}
+
+// StateNotifier is implemented by drivers that hear about state changes from
+// the device without being asked, for instance over a message broker. The
+// listener is called from the driver's own goroutine.
+type StateNotifier interface {
+	OnStateChange(listener func(state string))
+}
+
+var ErrNotSupported = errors.New("not supported by this device")
+
+// ErrChanging is wrapped by Status errors while the device is still on its
+// way to the state it was last switched to, so it can't be judged yet.
+var ErrChanging = errors.New("the device is still switching")
+
+type Factory func(settings yaml.Node) (Driver, error)
+
+var registry = make(map[string]Factory)
+
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+func New(name string, settings yaml.Node) (d Driver, err error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, errors.New("unknown driver \"" + name + "\"")
+	}
+	return factory(settings)
+}
+
+func Names() []string {
+	names := functions.GetKeys(registry)
+	sort.Strings(names)
+	return names
+}
+
+var unknownSettingRe = regexp.MustCompile(`field (\S+) not found in type`)
+
+// decodeSettings fails on keys the driver doesn't know, so a typo in a
+// setting stops the config from loading instead of leaving it unset.
+func decodeSettings(settings yaml.Node, out any) error {
+	if settings.Kind == 0 {
+		return nil
+	}
+	if err := settings.Decode(out); err != nil {
+		return err
+	}
+	buf, err := yaml.Marshal(&settings)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(buf))
+	decoder.KnownFields(true)
+	err = decoder.Decode(out)
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		for _, e := range te.Errors {
+			if m := unknownSettingRe.FindStringSubmatch(e); m != nil {
+				return fmt.Errorf("line %d: unknown setting \"%s\"", settingLine(settings, m[1]), m[1])
+			}
+		}
+	}
+	return err
+}
+
+// settingLine finds the line of a setting in the jobs config.
+func settingLine(settings yaml.Node, key string) int {
+	for i := 0; i+1 < len(settings.Content); i += 2 {
+		if settings.Content[i].Value == key {
+			return settings.Content[i].Line
+		}
+	}
+	return settings.Line
+}
@@ -0,0 +1,51 @@
+package drivers_test
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vishhvaan/lab-bot/drivers"
+)
+
+// newDriver makes a driver from settings written as they are in the jobs
+// config.
+func newDriver(t *testing.T, name string, settings string) drivers.Driver {
+	t.Helper()
+	d, err := tryDriver(name, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func tryDriver(name string, settings string) (drivers.Driver, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(settings), &doc); err != nil {
+		return nil, err
+	}
+	return drivers.New(name, *doc.Content[0])
+}
+
+func wantStatus(t *testing.T, d drivers.Driver, want string) {
+	t.Helper()
+	state, err := d.(drivers.StatusReader).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != want {
+		t.Fatalf("status is %q, want %q", state, want)
+	}
+}
+
+func TestUnknownSetting(t *testing.T) {
+	_, err := tryDriver("mqtt", `
+broker: tcp://localhost:1883
+comand_topic: cmnd/coffee/POWER`)
+	if err == nil || err.Error() != `line 3: unknown setting "comand_topic"` {
+		t.Fatalf("got %v, want the unknown setting", err)
+	}
+	if _, err := tryDriver("kasa", "adress: 10.0.0.5"); err == nil {
+		t.Fatal("kasa took an unknown setting")
+	}
+}
@@ -0,0 +1,94 @@
+// Package fakes imitates lab devices, so the drivers can be tried out and
+// tested without the hardware. The fake* commands run them on their own.
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/vishhvaan/lab-bot/drivers"
+)
+
+// KasaPlug imitates a Kasa HS110 plug on the local network. Its energy meter
+// reports Watts while the relay is on.
+type KasaPlug struct {
+	Watts float64
+	Log   *log.Logger
+	mu    sync.Mutex
+	relay int
+}
+
+// Serve answers the connections of the listener until it is closed.
+func (p *KasaPlug) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go p.serve(conn)
+	}
+}
+
+// On tells whether the relay is on.
+func (p *KasaPlug) On() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.relay == 1
+}
+
+func (p *KasaPlug) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		request, err := drivers.ReadKasaFrame(conn)
+		if err != nil {
+			return
+		}
+		logf(p.Log, "request: %s", request)
+
+		response := p.handle(request)
+		logf(p.Log, "response: %s", response)
+		if err = drivers.WriteKasaFrame(conn, response); err != nil {
+			return
+		}
+	}
+}
+
+func (p *KasaPlug) handle(request []byte) []byte {
+	var r struct {
+		System struct {
+			GetSysinfo    *struct{} `json:"get_sysinfo"`
+			SetRelayState *struct {
+				State int `json:"state"`
+			} `json:"set_relay_state"`
+		} `json:"system"`
+		Emeter struct {
+			GetRealtime *struct{} `json:"get_realtime"`
+		} `json:"emeter"`
+	}
+	if err := json.Unmarshal(request, &r); err != nil {
+		return []byte(`{"err_code":-1,"err_msg":"invalid json"}`)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case r.System.SetRelayState != nil:
+		p.relay = r.System.SetRelayState.State
+		return []byte(`{"system":{"set_relay_state":{"err_code":0}}}`)
+	case r.System.GetSysinfo != nil:
+		return []byte(fmt.Sprintf(`{"system":{"get_sysinfo":{"alias":"fake plug","model":"HS110(US)","relay_state":%d,"err_code":0}}}`, p.relay))
+	case r.Emeter.GetRealtime != nil:
+		return []byte(fmt.Sprintf(`{"emeter":{"get_realtime":{"power_mw":%.0f,"err_code":0}}}`, p.Watts*1000*float64(p.relay)))
+	}
+	return []byte(`{"err_code":-2,"err_msg":"module not support"}`)
+}
+
+// logf logs to the logger of a fake, when it has one.
+func logf(l *log.Logger, format string, v ...any) {
+	if l != nil {
+		l.Printf(format, v...)
+	}
+}
@@ -0,0 +1,391 @@
+package fakes
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// MQTTBroker is a tiny MQTT 3.1.1 broker with a Tasmota-style plug attached.
+// The plug listens on cmnd/<plug>/POWER and reports on stat/<plug>/POWER.
+//
+// Only what the mqtt driver needs is implemented: messages are delivered to
+// subscribers at QoS 0, retained messages are kept, + and # wildcards work.
+type MQTTBroker struct {
+	Log      *log.Logger
+	mu       sync.Mutex
+	clients  map[*mqttClient]bool
+	retained map[string][]byte
+	plug     *tasmotaPlug
+}
+
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetPubrec      = 5
+	packetPubrel      = 6
+	packetPubcomp     = 7
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetUnsubscribe = 10
+	packetUnsuback    = 11
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+)
+
+type mqttClient struct {
+	conn   net.Conn
+	wmu    sync.Mutex
+	topics []string
+}
+
+type tasmotaPlug struct {
+	mu    sync.Mutex
+	on    bool
+	topic string
+}
+
+// NewMQTTBroker makes a broker with a plug that has the given name in its
+// topics.
+func NewMQTTBroker(plug string) *MQTTBroker {
+	return &MQTTBroker{
+		clients:  make(map[*mqttClient]bool),
+		retained: make(map[string][]byte),
+		plug:     &tasmotaPlug{topic: plug},
+	}
+}
+
+// Serve answers the connections of the listener until it is closed.
+func (b *MQTTBroker) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go b.serve(&mqttClient{conn: conn})
+	}
+}
+
+// PressButton switches the plug by hand, "on", "off" or "toggle", behind
+// the bot's back. The plug reports its new state like it would.
+func (b *MQTTBroker) PressButton(action string) error {
+	switch action {
+	case "on":
+		b.plug.set(true)
+	case "off":
+		b.plug.set(false)
+	case "toggle":
+		b.plug.toggle()
+	default:
+		return errors.New("the plug's button can be pressed on, off or toggle")
+	}
+	b.reportPlug()
+	return nil
+}
+
+// PlugOn tells whether the plug is on.
+func (b *MQTTBroker) PlugOn() bool {
+	return b.plug.state() == "ON"
+}
+
+func (p *tasmotaPlug) set(on bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.on = on
+}
+
+func (p *tasmotaPlug) toggle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.on = !p.on
+}
+
+func (p *tasmotaPlug) state() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// plugCommand handles what the plug's firmware would: ON, OFF, TOGGLE, and
+// an empty payload asking for the current state.
+func (b *MQTTBroker) plugCommand(payload string) {
+	switch strings.ToUpper(strings.TrimSpace(payload)) {
+	case "ON", "1":
+		b.plug.set(true)
+	case "OFF", "0":
+		b.plug.set(false)
+	case "TOGGLE", "2":
+		b.plug.toggle()
+	case "":
+	default:
+		logf(b.Log, "plug ignored command %q", payload)
+		return
+	}
+	b.reportPlug()
+}
+
+func (b *MQTTBroker) reportPlug() {
+	state := b.plug.state()
+	logf(b.Log, "plug is %s", state)
+	b.publish("stat/"+b.plug.topic+"/POWER", []byte(state), false)
+}
+
+func (b *MQTTBroker) serve(c *mqttClient) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			if err != io.EOF {
+				logf(b.Log, "%s: %v", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		switch header >> 4 {
+		case packetConnect:
+			b.mu.Lock()
+			b.clients[c] = true
+			b.mu.Unlock()
+			c.write(packetConnack<<4, []byte{0, 0})
+		case packetPublish:
+			err = b.received(c, header, body)
+		case packetPubrel:
+			if len(body) >= 2 {
+				c.write(packetPubcomp<<4, body[:2])
+			}
+		case packetSubscribe:
+			err = b.subscribe(c, body)
+		case packetUnsubscribe:
+			err = b.unsubscribe(c, body)
+		case packetPingreq:
+			c.write(packetPingresp<<4, nil)
+		case packetDisconnect:
+			return
+		}
+		if err != nil {
+			logf(b.Log, "%s: %v", c.conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func (b *MQTTBroker) received(c *mqttClient, header byte, body []byte) error {
+	qos := (header >> 1) & 3
+	retain := header&1 == 1
+
+	topic, rest, err := readString(body)
+	if err != nil {
+		return err
+	}
+	if qos > 0 {
+		if len(rest) < 2 {
+			return errors.New("publish without packet id")
+		}
+		id := rest[:2]
+		rest = rest[2:]
+		if qos == 1 {
+			c.write(packetPuback<<4, id)
+		} else {
+			c.write(packetPubrec<<4, id)
+		}
+	}
+
+	logf(b.Log, "publish %s: %s", topic, rest)
+	b.publish(topic, rest, retain)
+	if topic == "cmnd/"+b.plug.topic+"/POWER" {
+		b.plugCommand(string(rest))
+	}
+	return nil
+}
+
+func (b *MQTTBroker) publish(topic string, payload []byte, retain bool) {
+	b.mu.Lock()
+	if retain {
+		if len(payload) == 0 {
+			delete(b.retained, topic)
+		} else {
+			b.retained[topic] = payload
+		}
+	}
+	var subscribers []*mqttClient
+	for c := range b.clients {
+		for _, filter := range c.topics {
+			if topicMatches(filter, topic) {
+				subscribers = append(subscribers, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, c := range subscribers {
+		c.publish(topic, payload, false)
+	}
+}
+
+func (b *MQTTBroker) subscribe(c *mqttClient, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("subscribe without packet id")
+	}
+	id, rest := body[:2], body[2:]
+
+	var filters []string
+	granted := append([]byte{}, id...)
+	for len(rest) > 0 {
+		filter, more, err := readString(rest)
+		if err != nil || len(more) < 1 {
+			return errors.New("malformed subscribe")
+		}
+		filters = append(filters, filter)
+		granted = append(granted, 0)
+		rest = more[1:]
+	}
+
+	b.mu.Lock()
+	c.topics = append(c.topics, filters...)
+	retained := make(map[string][]byte)
+	for topic, payload := range b.retained {
+		for _, filter := range filters {
+			if topicMatches(filter, topic) {
+				retained[topic] = payload
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	c.write(packetSuback<<4, granted)
+	for topic, payload := range retained {
+		c.publish(topic, payload, true)
+	}
+	return nil
+}
+
+func (b *MQTTBroker) unsubscribe(c *mqttClient, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("unsubscribe without packet id")
+	}
+	id, rest := body[:2], body[2:]
+
+	b.mu.Lock()
+	for len(rest) > 0 {
+		filter, more, err := readString(rest)
+		if err != nil {
+			b.mu.Unlock()
+			return err
+		}
+		for i, t := range c.topics {
+			if t == filter {
+				c.topics = append(c.topics[:i], c.topics[i+1:]...)
+				break
+			}
+		}
+		rest = more
+	}
+	b.mu.Unlock()
+
+	c.write(packetUnsuback<<4, id)
+	return nil
+}
+
+func (c *mqttClient) publish(topic string, payload []byte, retain bool) {
+	header := byte(packetPublish << 4)
+	if retain {
+		header |= 1
+	}
+	body := appendString(nil, topic)
+	c.write(header, append(body, payload...))
+}
+
+func (c *mqttClient) write(header byte, body []byte) {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 128
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.Write(packet)
+}
+
+func readPacket(r *bufio.Reader) (header byte, body []byte, err error) {
+	header, err = r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&127) * multiplier
+		multiplier *= 128
+		if digit&128 == 0 {
+			break
+		}
+	}
+	body = make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func readString(b []byte) (s string, rest []byte, err error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("string too short")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("string too short")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+func topicMatches(filter string, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
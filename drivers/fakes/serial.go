@@ -0,0 +1,124 @@
+//go:build linux
+
+package fakes
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Instrument imitates an instrument on a serial port. Commands end with \r
+// or \n and are answered with OK, or ON/OFF for the status command.
+type Instrument struct {
+	OnCommand     string
+	OffCommand    string
+	StatusCommand string
+	Log           *log.Logger
+	mu            sync.Mutex
+	on            bool
+}
+
+// OpenPTY opens a pseudo-terminal for the instrument to answer on, and gives
+// the device to put in the controller settings.
+func OpenPTY() (master *os.File, device string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	fd := int(master.Fd())
+	if err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+// Set flips the instrument's switch by hand, behind the bot's back.
+func (inst *Instrument) Set(on bool) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	inst.on = on
+	logf(inst.Log, "instrument on: %t", on)
+}
+
+// On tells whether the instrument is on.
+func (inst *Instrument) On() bool {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	return inst.on
+}
+
+// Serve answers commands until the pseudo-terminal breaks or is closed.
+// Reads fail with EIO while nobody has the device open, so it waits for the
+// bot to (re)open it.
+func (inst *Instrument) Serve(master *os.File) error {
+	for {
+		err := inst.answer(master)
+		if !errors.Is(err, unix.EIO) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (inst *Instrument) answer(master *os.File) error {
+	scanner := bufio.NewScanner(master)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+		if command == "" {
+			continue
+		}
+		logf(inst.Log, "command: %q", command)
+
+		reply := "ERR"
+		switch command {
+		case inst.OnCommand:
+			inst.Set(true)
+			reply = "OK"
+		case inst.OffCommand:
+			inst.Set(false)
+			reply = "OK"
+		case inst.StatusCommand:
+			reply = "OFF"
+			if inst.On() {
+				reply = "ON"
+			}
+		}
+		logf(inst.Log, "reply: %q", reply)
+		if _, err := master.WriteString(reply + "\r\n"); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// scanLines splits on \r as well as \n.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, b := range data {
+		if b == '\r' || b == '\n' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
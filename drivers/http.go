@@ -0,0 +1,278 @@
+package drivers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const httpMaxBody = 1 << 20
+
+// httpDevice sends a request for each action, for instruments with a small
+// REST endpoint.
+type httpDevice struct {
+	Vars      map[string]string `yaml:"vars"`
+	Headers   map[string]string `yaml:"headers"`
+	InitReq   *httpRequest      `yaml:"init"`
+	OnReq     *httpRequest      `yaml:"on"`
+	OffReq    *httpRequest      `yaml:"off"`
+	StatusReq *httpRequest      `yaml:"status"`
+	StateOn   string            `yaml:"state_on"`
+	StateOff  string            `yaml:"state_off"`
+	Timeout   time.Duration     `yaml:"timeout"`
+	client    *http.Client
+}
+
+// httpRequest succeeds when the response has one of the expected status
+// codes (any 2xx by default) and, with a jsonpath, when the value there
+// equals match. For status requests the value is compared with state_on and
+// state_off instead.
+type httpRequest struct {
+	Method   string            `yaml:"method"`
+	URL      string            `yaml:"url"`
+	Body     string            `yaml:"body"`
+	Headers  map[string]string `yaml:"headers"`
+	Expect   []int             `yaml:"expect_status"`
+	JSONPath string            `yaml:"jsonpath"`
+	Match    string            `yaml:"match"`
+	url      *commandTemplate
+	body     *commandTemplate
+	headers  map[string]*commandTemplate
+}
+
+type httpStatus struct {
+	*httpDevice
+}
+
+func init() {
+	Register("http", newHTTP)
+}
+
+func newHTTP(settings yaml.Node) (Driver, error) {
+	h := &httpDevice{
+		StateOn:  "on",
+		StateOff: "off",
+		Timeout:  5 * time.Second,
+	}
+	if err := decodeSettings(settings, h); err != nil {
+		return nil, err
+	}
+	if h.OnReq == nil || h.OffReq == nil {
+		return nil, errors.New("http driver needs an on and an off request")
+	}
+
+	for action, r := range map[string]*httpRequest{
+		"init":   h.InitReq,
+		"on":     h.OnReq,
+		"off":    h.OffReq,
+		"status": h.StatusReq,
+	} {
+		if r == nil {
+			continue
+		}
+		if err := r.parse(action, h.Headers); err != nil {
+			return nil, fmt.Errorf("invalid %s request: %w", action, err)
+		}
+	}
+	if h.StatusReq != nil && h.StatusReq.JSONPath == "" {
+		return nil, errors.New("http status request needs a jsonpath to the device state")
+	}
+
+	h.client = &http.Client{Timeout: h.Timeout}
+	if h.StatusReq != nil {
+		return httpStatus{h}, nil
+	}
+	return h, nil
+}
+
+func (r *httpRequest) parse(action string, headers map[string]string) (err error) {
+	if r.URL == "" {
+		return errors.New("missing url")
+	}
+	if r.Method == "" {
+		r.Method = http.MethodGet
+		if r.Body != "" {
+			r.Method = http.MethodPost
+		}
+	}
+	r.Method = strings.ToUpper(r.Method)
+	if r.url, err = parseTemplate(action+" url", r.URL); err != nil {
+		return err
+	}
+	if r.body, err = parseTemplate(action+" body", r.Body); err != nil {
+		return err
+	}
+
+	r.headers = make(map[string]*commandTemplate)
+	for _, hs := range []map[string]string{headers, r.Headers} {
+		for k, v := range hs {
+			if r.headers[k], err = parseTemplate(action+" header "+k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *httpDevice) Init() error {
+	if h.InitReq == nil {
+		return nil
+	}
+	return h.expectMatch(h.InitReq, "init")
+}
+
+func (h *httpDevice) On() error {
+	return h.expectMatch(h.OnReq, "on")
+}
+
+func (h *httpDevice) Off() error {
+	return h.expectMatch(h.OffReq, "off")
+}
+
+func (h httpStatus) Status() (state string, err error) {
+	value, err := h.send(h.StatusReq, "status")
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.EqualFold(value, h.StateOn):
+		return "on", nil
+	case strings.EqualFold(value, h.StateOff):
+		return "off", nil
+	}
+	return "", errors.New("unrecognized state " + shorten(value))
+}
+
+func (h *httpDevice) expectMatch(r *httpRequest, action string) error {
+	value, err := h.send(r, action)
+	if err != nil {
+		return err
+	}
+	if r.JSONPath != "" && value != r.Match {
+		return fmt.Errorf("%s request returned %s = %s, expected %s", action, r.JSONPath, shorten(value), r.Match)
+	}
+	return nil
+}
+
+// send makes the request and returns the value at its jsonpath, if it has one.
+func (h *httpDevice) send(r *httpRequest, action string) (value string, err error) {
+	url, err := r.url.render(h.Vars, action)
+	if err != nil {
+		return "", err
+	}
+	body, err := r.body.render(h.Vars, action)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(r.Method, url, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	for k, ct := range r.headers {
+		v, err := ct.render(h.Vars, action)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, httpMaxBody))
+	if err != nil {
+		return "", err
+	}
+
+	if !r.statusOK(resp.StatusCode) {
+		err = fmt.Errorf("%s request returned %s", action, resp.Status)
+		if message := shorten(string(respBody)); message != "" {
+			err = fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	if r.JSONPath == "" {
+		return "", nil
+	}
+
+	var doc any
+	if err = json.Unmarshal(respBody, &doc); err != nil {
+		return "", fmt.Errorf("%s response is not JSON: %w", action, err)
+	}
+	v, err := jsonPath(doc, r.JSONPath)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	buf, err := json.Marshal(v)
+	return string(buf), err
+}
+
+func (r *httpRequest) statusOK(code int) bool {
+	if len(r.Expect) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range r.Expect {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonPath supports the plain paths devices need, like $.relay[0].ison
+func jsonPath(doc any, path string) (value any, err error) {
+	rest := strings.TrimPrefix(path, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+	value = doc
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, errors.New("jsonpath " + path + ": " + key + " is not in an object")
+			}
+			if value, ok = object[key]; !ok {
+				return nil, errors.New("jsonpath " + path + ": no " + key + " in response")
+			}
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, errors.New("jsonpath " + path + ": missing ]")
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, errors.New("jsonpath " + path + ": only numeric indexes are supported")
+			}
+			rest = rest[end+1:]
+			array, ok := value.([]any)
+			if !ok || index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("jsonpath %s: no element %d in response", path, index)
+			}
+			value = array[index]
+		default:
+			return nil, errors.New("jsonpath " + path + ": expected . or [")
+		}
+	}
+	return value, nil
+}
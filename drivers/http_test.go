@@ -0,0 +1,136 @@
+package drivers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// relay is a small REST endpoint like the ones on network relays.
+type relay struct {
+	mu       sync.Mutex
+	on       bool
+	requests []string
+}
+
+func (r *relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.RequestURI()+" "+req.Header.Get("X-Token")+" "+string(body))
+	switch req.URL.Path {
+	case "/relay/0":
+		r.on = req.URL.Query().Get("turn") == "on"
+		io.WriteString(w, `{"ison":`+strconv.FormatBool(r.on)+`}`)
+	case "/status":
+		io.WriteString(w, `{"relays":[{"ison":`+strconv.FormatBool(r.on)+`}]}`)
+	case "/broken":
+		http.Error(w, "relay is broken", http.StatusInternalServerError)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	r := &relay{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	d := newDriver(t, "http", `
+vars:
+  base: `+server.URL+`
+  token: secret
+headers:
+  X-Token: "{{.token}}"
+on:
+  url: "{{.base}}/relay/0?turn={{.action}}"
+  body: '{"turn":"{{.action}}"}'
+  jsonpath: $.ison
+  match: "true"
+off:
+  url: "{{.base}}/relay/0?turn={{.action}}"
+  jsonpath: $.ison
+  match: "false"
+status:
+  url: "{{.base}}/status"
+  jsonpath: $.relays[0].ison
+state_on: "true"
+state_off: "false"`)
+
+	wantStatus(t, d, "off")
+	if err := d.On(); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, d, "on")
+	if err := d.Off(); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, d, "off")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, want := range []string{
+		"GET /status secret ",
+		`POST /relay/0?turn=on secret {"turn":"on"}`,
+		"GET /status secret ",
+		"GET /relay/0?turn=off secret ",
+	} {
+		if r.requests[i] != want {
+			t.Errorf("request %d was %q, want %q", i, r.requests[i], want)
+		}
+	}
+}
+
+func TestHTTPStatusCodes(t *testing.T) {
+	server := httptest.NewServer(&relay{})
+	defer server.Close()
+
+	d := newDriver(t, "http", `
+on:
+  url: `+server.URL+`/broken
+off:
+  url: `+server.URL+`/missing
+  expect_status: [404]`)
+	err := d.On()
+	if err == nil || !strings.Contains(err.Error(), "500 Internal Server Error: relay is broken") {
+		t.Errorf("got %v, want the status and body of the response", err)
+	}
+	if err := d.Off(); err != nil {
+		t.Errorf("404 is expected, got %v", err)
+	}
+}
+
+func TestHTTPMatch(t *testing.T) {
+	server := httptest.NewServer(&relay{})
+	defer server.Close()
+
+	d := newDriver(t, "http", `
+on:
+  url: `+server.URL+`/relay/0?turn=off
+  jsonpath: $.ison
+  match: "true"
+off:
+  url: `+server.URL+`/relay/0?turn=off
+  jsonpath: $.relay
+  match: "false"`)
+	if err := d.On(); err == nil || !strings.Contains(err.Error(), "$.ison = false, expected true") {
+		t.Errorf("got %v, want the value that didn't match", err)
+	}
+	if err := d.Off(); err == nil || !strings.Contains(err.Error(), "no relay in response") {
+		t.Errorf("got %v, want the missing key", err)
+	}
+}
+
+func TestHTTPStatusNeedsJSONPath(t *testing.T) {
+	_, err := tryDriver("http", `
+on: {url: "http://relay/on"}
+off: {url: "http://relay/off"}
+status: {url: "http://relay/status"}`)
+	if err == nil {
+		t.Fatal("http driver took a status request without a jsonpath")
+	}
+}
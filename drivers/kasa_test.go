@@ -0,0 +1,63 @@
+package drivers_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishhvaan/lab-bot/drivers"
+	"github.com/vishhvaan/lab-bot/drivers/fakes"
+)
+
+func TestKasa(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	plug := &fakes.KasaPlug{Watts: 850}
+	go plug.Serve(ln)
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	d := newDriver(t, "kasa", "host: "+host+"\nport: "+port+"\nemeter: true")
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, d, "off")
+
+	if err := d.On(); err != nil {
+		t.Fatal(err)
+	}
+	if !plug.On() {
+		t.Fatal("the plug didn't turn on")
+	}
+	wantStatus(t, d, "on")
+	watts, err := d.(drivers.PowerMeter).Power()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watts != 850 {
+		t.Errorf("power is %g W, want 850 W", watts)
+	}
+
+	if err := d.Off(); err != nil {
+		t.Fatal(err)
+	}
+	if plug.On() {
+		t.Fatal("the plug didn't turn off")
+	}
+	wantStatus(t, d, "off")
+}
+
+func TestKasaUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	d := newDriver(t, "kasa", "host: "+host+"\nport: "+port+"\ntimeout: 1s")
+	if err := d.Init(); err == nil {
+		t.Fatal("Init worked without a plug")
+	}
+}
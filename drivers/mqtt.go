@@ -0,0 +1,209 @@
+package drivers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// mqttDevice switches devices that listen on an MQTT broker, such as
+// Tasmota plugs (cmnd/<name>/POWER, stat/<name>/POWER) or Zigbee2MQTT
+// devices (zigbee2mqtt/<name>/set with {"state":"ON"}, state in zigbee2mqtt/<name>).
+type mqttDevice struct {
+	Broker        string        `yaml:"broker"`
+	ClientID      string        `yaml:"client_id"`
+	Username      string        `yaml:"username"`
+	Password      string        `yaml:"password"`
+	CommandTopic  string        `yaml:"command_topic"`
+	PayloadOn     string        `yaml:"payload_on"`
+	PayloadOff    string        `yaml:"payload_off"`
+	StateTopic    string        `yaml:"state_topic"`
+	StateKey      string        `yaml:"state_key"`
+	StateOn       string        `yaml:"state_on"`
+	StateOff      string        `yaml:"state_off"`
+	QueryTopic    string        `yaml:"query_topic"`
+	QueryPayload  string        `yaml:"query_payload"`
+	QoS           byte          `yaml:"qos"`
+	Retain        bool          `yaml:"retain"`
+	Timeout       time.Duration `yaml:"timeout"`
+	client        mqtt.Client
+	mu            sync.Mutex
+	state         string
+	listeners     []func(state string)
+	stateReported chan string
+}
+
+func init() {
+	Register("mqtt", newMQTT)
+}
+
+func newMQTT(settings yaml.Node) (Driver, error) {
+	m := &mqttDevice{
+		PayloadOn:  "ON",
+		PayloadOff: "OFF",
+		StateOn:    "ON",
+		StateOff:   "OFF",
+		QoS:        1,
+		Timeout:    5 * time.Second,
+	}
+	if err := decodeSettings(settings, m); err != nil {
+		return nil, err
+	}
+	if m.Broker == "" {
+		return nil, errors.New("mqtt driver needs a broker, e.g. tcp://localhost:1883")
+	}
+	if m.CommandTopic == "" {
+		return nil, errors.New("mqtt driver needs a command_topic")
+	}
+	if m.QoS > 2 {
+		return nil, errors.New("mqtt qos must be 0, 1 or 2")
+	}
+	if m.ClientID == "" {
+		m.ClientID = "lab-bot-" + strings.NewReplacer("/", "-", "+", "", "#", "").Replace(m.CommandTopic)
+	}
+	m.stateReported = make(chan string, 16)
+	return m, nil
+}
+
+func (m *mqttDevice) Init() error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(m.Broker).
+		SetClientID(m.ClientID).
+		SetUsername(m.Username).
+		SetPassword(m.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(m.Timeout).
+		SetOnConnectHandler(m.subscribe)
+
+	if m.client != nil {
+		// loaded again, the listeners stay and the old connection goes
+		m.client.Disconnect(250)
+	} else {
+		go m.notify()
+	}
+	m.client = mqtt.NewClient(opts)
+	return m.wait(m.client.Connect())
+}
+
+// subscribe runs on every (re)connection, since the broker may forget
+// subscriptions when the connection drops.
+func (m *mqttDevice) subscribe(client mqtt.Client) {
+	if m.StateTopic == "" {
+		return
+	}
+	token := client.Subscribe(m.StateTopic, m.QoS, m.stateMessage)
+	if err := m.wait(token); err != nil {
+		log.WithError(err).WithField("topic", m.StateTopic).Error("Cannot subscribe to the MQTT state topic")
+		return
+	}
+	if m.QueryTopic != "" {
+		client.Publish(m.QueryTopic, m.QoS, false, m.QueryPayload)
+	}
+}
+
+func (m *mqttDevice) stateMessage(client mqtt.Client, msg mqtt.Message) {
+	state, err := m.parseState(msg.Payload())
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"topic":   msg.Topic(),
+			"payload": string(msg.Payload()),
+		}).Warn("Cannot read the state from an MQTT message")
+		return
+	}
+
+	m.mu.Lock()
+	m.state = state
+	m.mu.Unlock()
+	m.stateReported <- state
+}
+
+// notify hands reported states to the listeners one at a time, off the
+// MQTT client's goroutines, so a slow listener never holds up the client.
+func (m *mqttDevice) notify() {
+	for state := range m.stateReported {
+		m.mu.Lock()
+		listeners := m.listeners
+		m.mu.Unlock()
+		for _, listener := range listeners {
+			listener(state)
+		}
+	}
+}
+
+func (m *mqttDevice) parseState(payload []byte) (state string, err error) {
+	value := strings.TrimSpace(string(payload))
+	if m.StateKey != "" {
+		var fields map[string]any
+		if err = json.Unmarshal(payload, &fields); err != nil {
+			return "", err
+		}
+		v, ok := fields[m.StateKey]
+		if !ok {
+			return "", errors.New("state key " + m.StateKey + " not in message")
+		}
+		value = fmt.Sprint(v)
+	}
+
+	switch {
+	case strings.EqualFold(value, m.StateOn):
+		return "on", nil
+	case strings.EqualFold(value, m.StateOff):
+		return "off", nil
+	}
+	return "", errors.New("unknown state " + value)
+}
+
+func (m *mqttDevice) On() error {
+	return m.publish(m.PayloadOn)
+}
+
+func (m *mqttDevice) Off() error {
+	return m.publish(m.PayloadOff)
+}
+
+func (m *mqttDevice) publish(payload string) error {
+	if m.client == nil {
+		return errors.New("mqtt client is not connected")
+	}
+	return m.wait(m.client.Publish(m.CommandTopic, m.QoS, m.Retain, payload))
+}
+
+// Status is the last state the device reported on its state topic.
+func (m *mqttDevice) Status() (state string, err error) {
+	if m.StateTopic == "" {
+		return "", ErrNotSupported
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == "" {
+		return "", errors.New("the device has not reported its state yet")
+	}
+	return m.state, nil
+}
+
+func (m *mqttDevice) OnStateChange(listener func(state string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+	if m.state != "" {
+		// the device may have reported before anyone was listening
+		select {
+		case m.stateReported <- m.state:
+		default:
+		}
+	}
+}
+
+func (m *mqttDevice) wait(token mqtt.Token) error {
+	if !token.WaitTimeout(m.Timeout) {
+		return errors.New("timed out waiting for the mqtt broker")
+	}
+	return token.Error()
+}
@@ -0,0 +1,75 @@
+package drivers_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vishhvaan/lab-bot/drivers"
+	"github.com/vishhvaan/lab-bot/drivers/fakes"
+)
+
+func TestMQTT(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	broker := fakes.NewMQTTBroker("coffee")
+	go broker.Serve(ln)
+
+	d := newDriver(t, "mqtt", `
+broker: tcp://`+ln.Addr().String()+`
+command_topic: cmnd/coffee/POWER
+state_topic: stat/coffee/POWER
+query_topic: cmnd/coffee/POWER
+timeout: 2s`)
+	reported := make(chan string, 16)
+	d.(drivers.StateNotifier).OnStateChange(func(state string) {
+		reported <- state
+	})
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// the plug answers the query after connecting
+	wantReported(t, reported, "off")
+	wantStatus(t, d, "off")
+
+	if err := d.On(); err != nil {
+		t.Fatal(err)
+	}
+	wantReported(t, reported, "on")
+	if !broker.PlugOn() {
+		t.Fatal("the plug didn't turn on")
+	}
+	wantStatus(t, d, "on")
+
+	broker.PressButton("off")
+	wantReported(t, reported, "off")
+	wantStatus(t, d, "off")
+
+	// loading it again replaces the connection, so each state comes once
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	wantReported(t, reported, "off")
+	broker.PressButton("on")
+	wantReported(t, reported, "on")
+	select {
+	case state := <-reported:
+		t.Fatalf("the device reported %q again", state)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func wantReported(t *testing.T, reported chan string, want string) {
+	t.Helper()
+	select {
+	case state := <-reported:
+		if state != want {
+			t.Fatalf("the device reported %q, want %q", state, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("the device didn't report %q", want)
+	}
+}
@@ -0,0 +1,252 @@
+package drivers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+	"gopkg.in/yaml.v3"
+)
+
+// serialDevice sends ASCII commands to instruments on a serial port, such as
+// circulating baths and syringe pumps. The port stays open between commands,
+// since opening it resets some devices.
+type serialDevice struct {
+	Device     string        `yaml:"device"`
+	Baud       int           `yaml:"baud"`
+	DataBits   int           `yaml:"data_bits"`
+	Parity     string        `yaml:"parity"`
+	StopBits   int           `yaml:"stop_bits"`
+	Terminator string        `yaml:"terminator"`
+	Timeout    time.Duration `yaml:"timeout"`
+	InitCmd    string        `yaml:"init"`
+	InitReply  string        `yaml:"init_response"`
+	OnCmd      string        `yaml:"on"`
+	OnReply    string        `yaml:"on_response"`
+	OffCmd     string        `yaml:"off"`
+	OffReply   string        `yaml:"off_response"`
+	StatusCmd  string        `yaml:"status"`
+	StatusOn   string        `yaml:"status_on"`
+	StatusOff  string        `yaml:"status_off"`
+	mode       serial.Mode
+	replies    map[string]*regexp.Regexp
+	stateOnRe  *regexp.Regexp
+	stateOffRe *regexp.Regexp
+	mu         sync.Mutex
+	port       serial.Port
+}
+
+type serialStatus struct {
+	*serialDevice
+}
+
+var serialParities = map[string]serial.Parity{
+	"none":  serial.NoParity,
+	"odd":   serial.OddParity,
+	"even":  serial.EvenParity,
+	"mark":  serial.MarkParity,
+	"space": serial.SpaceParity,
+}
+
+func init() {
+	Register("serial", newSerial)
+}
+
+func newSerial(settings yaml.Node) (Driver, error) {
+	s := &serialDevice{
+		Baud:       9600,
+		DataBits:   8,
+		Parity:     "none",
+		StopBits:   1,
+		Terminator: "\r",
+		Timeout:    2 * time.Second,
+		StatusOn:   `(?i)\bon\b`,
+		StatusOff:  `(?i)\boff\b`,
+	}
+	if err := decodeSettings(settings, s); err != nil {
+		return nil, err
+	}
+	if s.Device == "" {
+		return nil, errors.New("serial driver needs the device of the port, like /dev/ttyUSB0")
+	}
+	if s.OnCmd == "" || s.OffCmd == "" {
+		return nil, errors.New("serial driver needs an on and an off command")
+	}
+
+	parity, ok := serialParities[strings.ToLower(s.Parity)]
+	if !ok {
+		return nil, errors.New("serial parity must be none, odd, even, mark or space")
+	}
+	stopBits := serial.OneStopBit
+	switch s.StopBits {
+	case 1:
+	case 2:
+		stopBits = serial.TwoStopBits
+	default:
+		return nil, errors.New("serial stop_bits must be 1 or 2")
+	}
+	s.mode = serial.Mode{
+		BaudRate: s.Baud,
+		DataBits: s.DataBits,
+		Parity:   parity,
+		StopBits: stopBits,
+	}
+
+	s.replies = make(map[string]*regexp.Regexp)
+	for action, reply := range map[string]string{
+		"init": s.InitReply,
+		"on":   s.OnReply,
+		"off":  s.OffReply,
+	} {
+		if reply == "" {
+			continue
+		}
+		re, err := regexp.Compile(reply)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s_response: %w", action, err)
+		}
+		s.replies[action] = re
+	}
+	var err error
+	if s.stateOnRe, err = regexp.Compile(s.StatusOn); err != nil {
+		return nil, fmt.Errorf("invalid status_on: %w", err)
+	}
+	if s.stateOffRe, err = regexp.Compile(s.StatusOff); err != nil {
+		return nil, fmt.Errorf("invalid status_off: %w", err)
+	}
+
+	if s.StatusCmd != "" {
+		return serialStatus{s}, nil
+	}
+	return s, nil
+}
+
+func (s *serialDevice) Init() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.open(); err != nil {
+		return err
+	}
+	if s.InitCmd == "" {
+		return nil
+	}
+	return s.expect("init", s.InitCmd)
+}
+
+func (s *serialDevice) On() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expect("on", s.OnCmd)
+}
+
+func (s *serialDevice) Off() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expect("off", s.OffCmd)
+}
+
+// Status matches the reply to the status command against status_on and
+// status_off.
+func (s serialStatus) Status() (state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reply, err := s.send(s.StatusCmd, true)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case s.stateOnRe.MatchString(reply):
+		return "on", nil
+	case s.stateOffRe.MatchString(reply):
+		return "off", nil
+	}
+	return "", errors.New("unrecognized status reply: " + shorten(reply))
+}
+
+// expect sends the command for an action and, when the action has an
+// expected response, checks the reply against it.
+func (s *serialDevice) expect(action string, command string) error {
+	re := s.replies[action]
+	reply, err := s.send(command, re != nil)
+	if err != nil {
+		return err
+	}
+	if re != nil && !re.MatchString(reply) {
+		return fmt.Errorf("%s command got the reply %q", action, shorten(reply))
+	}
+	return nil
+}
+
+func (s *serialDevice) open() (err error) {
+	if s.port != nil {
+		return nil
+	}
+	s.port, err = serial.Open(s.Device, &s.mode)
+	if err != nil {
+		s.port = nil
+		return fmt.Errorf("cannot open %s: %w", s.Device, err)
+	}
+	return nil
+}
+
+// send writes a command and, if asked, reads one line back. The port is
+// closed after an error so the next command reopens it, in case the adapter
+// was unplugged.
+func (s *serialDevice) send(command string, reply bool) (line string, err error) {
+	if err = s.open(); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			s.port.Close()
+			s.port = nil
+		}
+	}()
+
+	// drop anything the device said on its own since the last command
+	if err = s.port.ResetInputBuffer(); err != nil {
+		return "", err
+	}
+	if _, err = s.port.Write([]byte(command + s.Terminator)); err != nil {
+		return "", err
+	}
+	if !reply {
+		return "", nil
+	}
+	return s.readLine()
+}
+
+// readLine reads up to the end of a line, taking either \r or \n as the end
+// since devices rarely agree on it, and skipping empty lines.
+func (s *serialDevice) readLine() (line string, err error) {
+	deadline := time.Now().Add(s.Timeout)
+	var b strings.Builder
+	buf := make([]byte, 1)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return "", fmt.Errorf("no reply from %s within %s", s.Device, s.Timeout)
+		}
+		if err = s.port.SetReadTimeout(remaining); err != nil {
+			return "", err
+		}
+		n, err := s.port.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			continue
+		}
+		if buf[0] == '\r' || buf[0] == '\n' {
+			if b.Len() > 0 {
+				return strings.TrimSpace(b.String()), nil
+			}
+			continue
+		}
+		b.WriteByte(buf[0])
+	}
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package drivers_test
+
+import (
+	"testing"
+
+	"github.com/vishhvaan/lab-bot/drivers/fakes"
+)
+
+func TestSerial(t *testing.T) {
+	master, device, err := fakes.OpenPTY()
+	if err != nil {
+		t.Skip("no pseudo-terminals here: ", err)
+	}
+	defer master.Close()
+	inst := &fakes.Instrument{OnCommand: "ON", OffCommand: "OFF", StatusCommand: "STATUS?"}
+	go inst.Serve(master)
+
+	d := newDriver(t, "serial", `
+device: `+device+`
+on: "ON"
+on_response: ^OK$
+off: "OFF"
+off_response: ^OK$
+status: STATUS?`)
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, d, "off")
+
+	if err := d.On(); err != nil {
+		t.Fatal(err)
+	}
+	if !inst.On() {
+		t.Fatal("the instrument didn't turn on")
+	}
+	wantStatus(t, d, "on")
+
+	inst.Set(false)
+	wantStatus(t, d, "off")
+	if err := d.Off(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSerialUnexpectedReply(t *testing.T) {
+	master, device, err := fakes.OpenPTY()
+	if err != nil {
+		t.Skip("no pseudo-terminals here: ", err)
+	}
+	defer master.Close()
+	inst := &fakes.Instrument{OnCommand: "ON", OffCommand: "OFF", StatusCommand: "STATUS?"}
+	go inst.Serve(master)
+
+	d := newDriver(t, "serial", `
+device: `+device+`
+on: START
+on_response: ^OK$
+off: "OFF"`)
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.On(); err == nil {
+		t.Fatal("On worked though the instrument answered ERR")
+	}
+}
@@ -0,0 +1,210 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// shell runs a command for each action, for instruments that only come with
+// a vendor command line tool.
+type shell struct {
+	commandRunner `yaml:",inline"`
+	Vars          map[string]string `yaml:"vars"`
+	InitCmd       string            `yaml:"init"`
+	OnCmd         string            `yaml:"on"`
+	OffCmd        string            `yaml:"off"`
+	StatusCmd     string            `yaml:"status"`
+	StatusOn      string            `yaml:"status_on"`
+	StatusOff     string            `yaml:"status_off"`
+	commands      map[string]*commandTemplate
+	stateOnRe     *regexp.Regexp
+	stateOffRe    *regexp.Regexp
+}
+
+// shellStatus wraps shell so only controllers with a status command read the
+// device state.
+type shellStatus struct {
+	*shell
+}
+
+// commandRunner runs command lines for the shell and wol drivers. A command
+// fails when it exits with a code not in ExitCodes or runs past Timeout.
+type commandRunner struct {
+	Shell     string        `yaml:"shell"`
+	ExitCodes []int         `yaml:"exit_codes"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+func defaultCommandRunner() commandRunner {
+	return commandRunner{
+		Shell:     "/bin/sh",
+		ExitCodes: []int{0},
+		Timeout:   10 * time.Second,
+	}
+}
+
+func init() {
+	Register("shell", newShell)
+}
+
+func newShell(settings yaml.Node) (Driver, error) {
+	s := &shell{
+		commandRunner: defaultCommandRunner(),
+		StatusOn:      `(?i)\bon\b`,
+		StatusOff:     `(?i)\boff\b`,
+	}
+	if err := decodeSettings(settings, s); err != nil {
+		return nil, err
+	}
+	if s.OnCmd == "" || s.OffCmd == "" {
+		return nil, errors.New("shell driver needs an on and an off command")
+	}
+
+	s.commands = make(map[string]*commandTemplate)
+	for action, text := range map[string]string{
+		"init":   s.InitCmd,
+		"on":     s.OnCmd,
+		"off":    s.OffCmd,
+		"status": s.StatusCmd,
+	} {
+		ct, err := parseTemplate(action, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s command: %w", action, err)
+		}
+		s.commands[action] = ct
+	}
+
+	var err error
+	if s.stateOnRe, err = regexp.Compile(s.StatusOn); err != nil {
+		return nil, fmt.Errorf("invalid status_on: %w", err)
+	}
+	if s.stateOffRe, err = regexp.Compile(s.StatusOff); err != nil {
+		return nil, fmt.Errorf("invalid status_off: %w", err)
+	}
+
+	if s.StatusCmd != "" {
+		return shellStatus{s}, nil
+	}
+	return s, nil
+}
+
+func (s *shell) Init() error {
+	if s.InitCmd == "" {
+		return nil
+	}
+	_, err := s.run("init")
+	return err
+}
+
+func (s *shell) On() error {
+	_, err := s.run("on")
+	return err
+}
+
+func (s *shell) Off() error {
+	_, err := s.run("off")
+	return err
+}
+
+// Status matches the output of the status command against status_on and
+// status_off.
+func (s shellStatus) Status() (state string, err error) {
+	output, err := s.run("status")
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case s.stateOnRe.MatchString(output):
+		return "on", nil
+	case s.stateOffRe.MatchString(output):
+		return "off", nil
+	}
+	return "", errors.New("unrecognized status output: " + shorten(output))
+}
+
+func (s *shell) run(action string) (output string, err error) {
+	command, err := s.commands[action].render(s.Vars, action)
+	if err != nil {
+		return "", err
+	}
+	return s.commandRunner.run(action, command)
+}
+
+func (cr commandRunner) run(action string, command string) (output string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cr.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, cr.Shell, "-c", command)
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err = cmd.Start(); err != nil {
+		return "", err
+	}
+
+	// The output is read here rather than by Run, which would keep waiting
+	// past the timeout while a background process holds the output open.
+	var stdout, stderr bytes.Buffer
+	read := make(chan struct{}, 2)
+	go func() {
+		io.Copy(&stdout, stdoutPipe)
+		read <- struct{}{}
+	}()
+	go func() {
+		io.Copy(&stderr, stderrPipe)
+		read <- struct{}{}
+	}()
+wait:
+	for pending := 2; pending > 0; pending-- {
+		select {
+		case <-read:
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	err = cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s command timed out after %s", action, cr.Timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if !cr.exitCodeOK(exitErr.ExitCode()) {
+			err = fmt.Errorf("%s command exited with %d", action, exitErr.ExitCode())
+			message := shorten(stderr.String())
+			if message == "" {
+				message = shorten(stdout.String())
+			}
+			if message != "" {
+				err = fmt.Errorf("%w: %s", err, message)
+			}
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	} else if !cr.exitCodeOK(0) {
+		return "", fmt.Errorf("%s command exited with 0", action)
+	}
+	return stdout.String(), nil
+}
+
+func (cr commandRunner) exitCodeOK(code int) bool {
+	for _, c := range cr.ExitCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
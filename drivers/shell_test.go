@@ -0,0 +1,101 @@
+package drivers_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishhvaan/lab-bot/drivers"
+)
+
+func TestShellTemplates(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	d := newDriver(t, "shell", `
+vars:
+  name: bath
+on: echo {{.action}} {{.name}} > `+out+`
+off: echo {{.action}} {{.name}} > `+out)
+
+	for _, action := range []string{"on", "off"} {
+		var err error
+		if action == "on" {
+			err = d.On()
+		} else {
+			err = d.Off()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.TrimSpace(string(buf)), action+" bath"; got != want {
+			t.Errorf("%s ran %q, want %q", action, got, want)
+		}
+	}
+}
+
+func TestShellMissingVar(t *testing.T) {
+	d := newDriver(t, "shell", `
+on: echo {{.missing}}
+off: "true"`)
+	if err := d.On(); err == nil {
+		t.Fatal("On worked with a var that isn't set")
+	}
+}
+
+func TestShellStatus(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state")
+	d := newDriver(t, "shell", `
+on: "true"
+off: "true"
+status: cat `+state)
+
+	for output, want := range map[string]string{"Relay is ON": "on", "relay: off\n": "off"} {
+		if err := os.WriteFile(state, []byte(output), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		wantStatus(t, d, want)
+	}
+	os.WriteFile(state, []byte("standby"), 0o644)
+	if _, err := d.(drivers.StatusReader).Status(); err == nil {
+		t.Fatal("Status took standby for a state")
+	}
+}
+
+func TestShellExitCodes(t *testing.T) {
+	d := newDriver(t, "shell", `
+on: "true"
+off: exit 3
+exit_codes: [0, 3]`)
+	if err := d.Off(); err != nil {
+		t.Errorf("exit code 3 is allowed, got %v", err)
+	}
+
+	d = newDriver(t, "shell", `
+on: echo bath is jammed >&2; exit 3
+off: "true"`)
+	err := d.On()
+	if err == nil || !strings.Contains(err.Error(), "exited with 3: bath is jammed") {
+		t.Errorf("got %v, want the exit code and stderr", err)
+	}
+}
+
+func TestShellTimeout(t *testing.T) {
+	d := newDriver(t, "shell", `
+on: sleep 10
+off: "true"
+timeout: 200ms`)
+	start := time.Now()
+	err := d.On()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the command ran for %s past its timeout", elapsed)
+	}
+}
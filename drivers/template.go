@@ -0,0 +1,50 @@
+package drivers
+
+import (
+	"strings"
+	"text/template"
+)
+
+// commandTemplate is a command, URL or request body from the settings, with
+// {{.name}} placeholders filled from the driver's vars and {{.action}} set
+// to "on", "off", "status" or "init".
+type commandTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+func parseTemplate(name string, text string) (ct *commandTemplate, err error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &commandTemplate{text: text, tmpl: tmpl}, nil
+}
+
+func (ct *commandTemplate) render(vars map[string]string, action string) (string, error) {
+	if ct == nil {
+		return "", nil
+	}
+	data := map[string]string{"action": action}
+	for k, v := range vars {
+		data[k] = v
+	}
+	var b strings.Builder
+	if err := ct.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// shorten keeps error messages from devices readable in Slack.
+func shorten(output string) string {
+	const maxLen = 200
+	output = strings.TrimSpace(output)
+	if len(output) > maxLen {
+		output = output[:maxLen] + "…"
+	}
+	return output
+}
@@ -0,0 +1,78 @@
+package drivers
+
+import (
+	"errors"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// virtual has no device behind it, for trying out controllers and schedules.
+// It remembers the last command, and Simulate stands in for someone at the
+// device, so transcripts can exercise state polling.
+type virtual struct {
+	mu          sync.Mutex
+	state       string
+	unreachable bool
+}
+
+func init() {
+	Register("virtual", newVirtual)
+}
+
+func newVirtual(settings yaml.Node) (Driver, error) {
+	return &virtual{}, nil
+}
+
+func (v *virtual) Init() error {
+	return nil
+}
+
+func (v *virtual) On() error {
+	return v.set("on")
+}
+
+func (v *virtual) Off() error {
+	return v.set("off")
+}
+
+func (v *virtual) set(state string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.unreachable {
+		return errors.New("virtual device is unreachable")
+	}
+	v.state = state
+	return nil
+}
+
+// Status only knows the state once the device has been switched.
+func (v *virtual) Status() (state string, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch {
+	case v.unreachable:
+		return "", errors.New("virtual device is unreachable")
+	case v.state == "":
+		return "", ErrNotSupported
+	}
+	return v.state, nil
+}
+
+// Simulate switches the device "on" or "off" by hand, or makes it
+// "unreachable" (and "reachable" again).
+func (v *virtual) Simulate(change string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch change {
+	case "on", "off":
+		v.state = change
+	case "unreachable":
+		v.unreachable = true
+	case "reachable":
+		v.unreachable = false
+	default:
+		return errors.New("a virtual device can be switched on or off, or made unreachable or reachable")
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package drivers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	wolPort      = 9
+	wolProbePort = 22
+	wolSettle    = 3 * time.Minute
+)
+
+// wol wakes workstations with a Wake-on-LAN magic packet, shuts them down
+// with a command (usually over ssh), and checks whether they are up by
+// connecting to a TCP port.
+type wol struct {
+	commandRunner `yaml:",inline"`
+	Vars          map[string]string `yaml:"vars"`
+	MAC           string            `yaml:"mac"`
+	Broadcast     string            `yaml:"broadcast"`
+	Host          string            `yaml:"host"`
+	ProbePort     int               `yaml:"probe_port"`
+	ProbeTimeout  time.Duration     `yaml:"probe_timeout"`
+	Settle        time.Duration     `yaml:"settle"`
+	ShutdownCmd   string            `yaml:"shutdown"`
+	packet        []byte
+	shutdown      *commandTemplate
+	mu            sync.Mutex
+	lastAction    string
+	lastActionAt  time.Time
+}
+
+type wolStatus struct {
+	*wol
+}
+
+func init() {
+	Register("wol", newWOL)
+}
+
+func newWOL(settings yaml.Node) (Driver, error) {
+	w := &wol{
+		commandRunner: defaultCommandRunner(),
+		Broadcast:     "255.255.255.255",
+		ProbePort:     wolProbePort,
+		ProbeTimeout:  2 * time.Second,
+		Settle:        wolSettle,
+	}
+	w.Timeout = 30 * time.Second
+	if err := decodeSettings(settings, w); err != nil {
+		return nil, err
+	}
+
+	mac, err := net.ParseMAC(w.MAC)
+	if err != nil || len(mac) != 6 {
+		return nil, errors.New("wol driver needs the mac address of the workstation, like 00:11:22:33:44:55")
+	}
+	w.packet = magicPacket(mac)
+
+	if _, _, err := net.SplitHostPort(w.Broadcast); err != nil {
+		w.Broadcast = net.JoinHostPort(w.Broadcast, strconv.Itoa(wolPort))
+	}
+	if w.ShutdownCmd == "" {
+		return nil, errors.New("wol driver needs a shutdown command, e.g. ssh lab@{{.host}} sudo poweroff")
+	}
+	if w.shutdown, err = parseTemplate("shutdown", w.ShutdownCmd); err != nil {
+		return nil, fmt.Errorf("invalid shutdown command: %w", err)
+	}
+
+	if w.Host != "" {
+		if w.Vars == nil {
+			w.Vars = make(map[string]string)
+		}
+		if _, ok := w.Vars["host"]; !ok {
+			w.Vars["host"] = w.Host
+		}
+		return wolStatus{w}, nil
+	}
+	return w, nil
+}
+
+// magicPacket is six 0xff bytes followed by the MAC address 16 times.
+func magicPacket(mac net.HardwareAddr) []byte {
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for i := 0; i < 16; i++ {
+		packet = append(packet, mac...)
+	}
+	return packet
+}
+
+func (w *wol) Init() error {
+	return nil
+}
+
+func (w *wol) On() error {
+	conn, err := net.Dial("udp", w.Broadcast)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.Write(w.packet); err != nil {
+		return err
+	}
+	w.acted("on")
+	return nil
+}
+
+func (w *wol) Off() error {
+	command, err := w.shutdown.render(w.Vars, "off")
+	if err != nil {
+		return err
+	}
+	if _, err = w.run("shutdown", command); err != nil {
+		return err
+	}
+	w.acted("off")
+	return nil
+}
+
+func (w *wol) acted(action string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastAction = action
+	w.lastActionAt = time.Now()
+}
+
+// Status is "on" when the workstation accepts connections on the probe port.
+// Workstations take a while to boot and shut down, so for a while after a
+// power command a disagreeing probe is not taken as the answer.
+func (w wolStatus) Status() (state string, err error) {
+	address := net.JoinHostPort(w.Host, strconv.Itoa(w.ProbePort))
+	conn, err := net.DialTimeout("tcp", address, w.ProbeTimeout)
+	state = "off"
+	if err == nil {
+		conn.Close()
+		state = "on"
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if state != w.lastAction && time.Since(w.lastActionAt) < w.Settle {
+		if w.lastAction == "on" {
+			return "", fmt.Errorf("still waiting for %s to boot: %w", w.Host, ErrChanging)
+		}
+		return "", fmt.Errorf("still waiting for %s to shut down: %w", w.Host, ErrChanging)
+	}
+	return state, nil
+}
@@ -0,0 +1,71 @@
+package drivers_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWOLMagicPacket(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	d := newDriver(t, "wol", `
+mac: 00:11:22:aa:bb:cc
+broadcast: `+conn.LocalAddr().String()+`
+shutdown: "true"`)
+	if err := d.On(); err != nil {
+		t.Fatal(err)
+	}
+	wantMagicPacket(t, conn, net.HardwareAddr{0x00, 0x11, 0x22, 0xaa, 0xbb, 0xcc})
+}
+
+// Without a port in the broadcast address the packet goes to port 9, which
+// takes root to listen on.
+func TestWOLDefaultPort(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Skip("can't listen on port 9: ", err)
+	}
+	defer conn.Close()
+
+	d := newDriver(t, "wol", `
+mac: 00-11-22-AA-BB-CC
+broadcast: 127.0.0.1
+shutdown: "true"`)
+	if err := d.On(); err != nil {
+		t.Fatal(err)
+	}
+	wantMagicPacket(t, conn, net.HardwareAddr{0x00, 0x11, 0x22, 0xaa, 0xbb, 0xcc})
+}
+
+func wantMagicPacket(t *testing.T, conn net.PacketConn, mac net.HardwareAddr) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Repeat([]byte{0xff}, 6)
+	want = append(want, bytes.Repeat(mac, 16)...)
+	if !bytes.Equal(buf[:n], want) {
+		t.Fatalf("magic packet is % x, want % x", buf[:n], want)
+	}
+}
+
+func TestWOLNeedsMAC(t *testing.T) {
+	for _, settings := range []string{
+		`shutdown: "true"`,
+		"mac: 00:11:22:33:44\nshutdown: \"true\"",
+		"mac: 00:11:22:33:44:55:66:77\nshutdown: \"true\"",
+	} {
+		if _, err := tryDriver("wol", settings); err == nil {
+			t.Errorf("wol driver took %q", settings)
+		}
+	}
+}
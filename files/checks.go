@@ -15,3 +15,8 @@ func CheckFile(file string) {
 
 	}
 }
+
+func FileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
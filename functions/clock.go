@@ -0,0 +1,113 @@
+package functions
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time for uptimes, birthdays and
+// schedules. It is the wall clock unless a FakeClock is installed.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+var clock Clock = realClock{}
+
+func Now() time.Time {
+	return clock.Now()
+}
+
+func SetClock(c Clock) {
+	clock = c
+}
+
+// Faked tells whether a FakeClock is installed. Fake time only moves when
+// its owner sets it, so work due at a time is run by the owner rather than
+// by timers.
+func Faked() bool {
+	_, ok := clock.(*FakeClock)
+	return ok
+}
+
+// AfterFunc calls f in its own goroutine once d has passed, like
+// time.AfterFunc. Under a FakeClock it arms nothing and returns nil.
+func AfterFunc(d time.Duration, f func()) *time.Timer {
+	if Faked() {
+		return nil
+	}
+	return time.AfterFunc(d, f)
+}
+
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *FakeClock) Set(t time.Time) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = t
+}
+
+var timeOfDayLayouts = []string{"15:04", "3:04pm", "3pm"}
+
+// ParseTimeOfDay reads times like 17:30, 5:30pm, 7am, noon and midnight.
+func ParseTimeOfDay(text string) (hour int, minute int, err error) {
+	text = strings.ToLower(strings.ReplaceAll(text, " ", ""))
+	switch text {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+	for _, layout := range timeOfDayLayouts {
+		t, err := time.Parse(layout, text)
+		if err == nil {
+			return t.Hour(), t.Minute(), nil
+		}
+	}
+	return 0, 0, errors.New("not a time of day")
+}
+
+// ParseDay reads days like 2026-12-28, today, tomorrow or a weekday, which
+// means the next one after today. It returns the start of the day.
+func ParseDay(text string, now time.Time) (day time.Time, err error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	text = strings.ToLower(text)
+	switch text {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if text == name || text == name[:3] {
+			days := (int(d)-int(today.Weekday())+6)%7 + 1
+			return today.AddDate(0, 0, days), nil
+		}
+	}
+	day, err = time.ParseInLocation("2006-01-02", text, now.Location())
+	if err != nil {
+		return day, errors.New("not a day")
+	}
+	return day, nil
+}
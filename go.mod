@@ -3,28 +3,29 @@ module github.com/vishhvaan/lab-bot
 go 1.18
 
 require (
+	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/elastic/go-sysinfo v1.8.0
+	github.com/go-co-op/gocron v1.15.0
+	github.com/lnquy/cron v1.1.1
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/sashabaranov/go-gpt3 v0.0.0-20230128191859-3695eb3ade92
 	github.com/sirupsen/logrus v1.8.1
 	github.com/slack-go/slack v0.9.4
+	go.bug.st/serial v1.5.0
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/sys v0.6.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de // indirect
-	github.com/elastic/go-sysinfo v1.8.0 // indirect
+	github.com/creack/goselect v0.1.2 // indirect
 	github.com/elastic/go-windows v1.0.0 // indirect
-	github.com/go-co-op/gocron v1.15.0 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/joeshaw/multierror v0.0.0-20140124173710-69b34d4ec901 // indirect
-	github.com/kr/pretty v0.1.0 // indirect
-	github.com/lnquy/cron v1.1.1 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/prometheus/procfs v0.0.0-20190425082905-87a4384529e0 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
-	github.com/sashabaranov/go-gpt3 v0.0.0-20230128191859-3695eb3ade92 // indirect
-	github.com/stretchr/testify v1.7.5 // indirect
-	go.etcd.io/bbolt v1.3.6 // indirect
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
-	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
 	howett.net/plist v0.0.0-20181124034731-591f970eefbb // indirect
 )
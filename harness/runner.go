@@ -0,0 +1,378 @@
+package harness
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/vishhvaan/lab-bot/config"
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/functions"
+	"github.com/vishhvaan/lab-bot/jobs"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+const (
+	botUserID      = "ULABBOT"
+	botChannel     = "lab-bot-channel"
+	defaultChannel = "general"
+	timeLayout     = "2006-01-02 15:04"
+)
+
+var defaultStart = time.Date(2026, time.January, 5, 9, 0, 0, 0, time.Local)
+
+var mentionRe = regexp.MustCompile(`@([A-Za-z0-9._-]+)`)
+var userIDRe = regexp.MustCompile(`<@(U[A-Z0-9._-]+)>`)
+
+type Failure struct {
+	Line     int
+	Input    string
+	Expected []string
+	Actual   []string
+}
+
+func (f Failure) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "line %d: %s\n", f.Line, f.Input)
+	for _, l := range f.Expected {
+		b.WriteString("  - " + l + "\n")
+	}
+	for _, l := range f.Actual {
+		b.WriteString("  + " + l + "\n")
+	}
+	return b.String()
+}
+
+type Result struct {
+	Path     string
+	Failures []Failure
+}
+
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// simulator is implemented by the virtual driver.
+type simulator interface {
+	Simulate(change string) error
+}
+
+type runner struct {
+	messenger *slack.MemoryMessenger
+	clock     *functions.FakeClock
+	handler   *jobs.JobHandler
+	config    config.JobsConfig
+	channel   string
+	started   bool
+	messages  int
+}
+
+// Run replays a transcript against a fresh database and the jobs in
+// jobsFile, or in the jobs.yml next to the transcript if jobsFile is empty.
+// Lab members come from the members.yml next to the jobs file, if there is one.
+// With update set, the transcript is rewritten with the bot's actual output.
+func Run(path string, jobsFile string, update bool) (result Result, err error) {
+	t, err := parseTranscript(path)
+	if err != nil {
+		return result, err
+	}
+	result.Path = path
+
+	if jobsFile == "" {
+		jobsFile = filepath.Join(filepath.Dir(path), "jobs.yml")
+	}
+	jobsConfig, err := config.LoadJobs(jobsFile)
+	if err != nil {
+		return result, err
+	}
+	config.Members, err = config.LoadMembers(filepath.Join(filepath.Dir(jobsFile), "members.yml"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+
+	dir, err := os.MkdirTemp("", "lab-bot-transcript")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(dir)
+	db.OpenAt(filepath.Join(dir, "bot.db"))
+	defer db.Close()
+
+	r := &runner{
+		messenger: slack.NewMemoryMessenger(botChannel),
+		clock:     functions.NewFakeClock(defaultStart),
+		channel:   defaultChannel,
+	}
+	functions.SetClock(r.clock)
+	r.messenger.Users[botUserID] = "lab-bot"
+	defer func() {
+		if r.handler != nil {
+			r.handler.StopJobs()
+		}
+	}()
+
+	actual := make([][]string, len(t.steps))
+	for i, s := range t.steps {
+		if !r.started {
+			if err = r.start(s.input, jobsConfig); err != nil {
+				return result, err
+			}
+		}
+		r.messenger.Reset()
+		if err = r.step(s.input); err != nil {
+			return result, fmt.Errorf("%s:%d: %w", path, s.line, err)
+		}
+		actual[i] = r.render(r.messenger.Calls())
+		if !equalLines(s.expected, actual[i]) {
+			result.Failures = append(result.Failures, Failure{
+				Line:     s.line,
+				Input:    s.input,
+				Expected: s.expected,
+				Actual:   actual[i],
+			})
+		}
+	}
+
+	if update && !result.Passed() {
+		err = t.write(actual)
+	}
+	return result, err
+}
+
+// start creates the jobs, at the time of the first @clock if the transcript
+// begins with one. Output from loading the jobs is not part of the transcript.
+func (r *runner) start(input string, jobsConfig config.JobsConfig) (err error) {
+	fields := strings.Fields(input)
+	if len(fields) > 1 && fields[0] == "@clock" {
+		if t, err := parseTime(strings.Join(fields[1:], " ")); err == nil {
+			r.clock.Set(t)
+		}
+	}
+	r.config = jobsConfig
+	if err = r.load(); err != nil {
+		return err
+	}
+	r.started = true
+	return nil
+}
+
+func (r *runner) load() (err error) {
+	if r.handler != nil {
+		r.handler.StopJobs()
+	}
+	r.handler, err = jobs.CreateHandler(r.messenger, r.config)
+	if err != nil {
+		return err
+	}
+	r.handler.InitJobs()
+	return nil
+}
+
+func (r *runner) step(input string) error {
+	if strings.HasPrefix(input, "@") {
+		return r.directive(strings.Fields(input))
+	}
+
+	i := strings.Index(input, ":")
+	who := strings.Fields(input[:i])
+	text := strings.TrimSpace(input[i+1:])
+	if len(who) == 0 || len(who) > 2 {
+		return errors.New("malformed message line")
+	}
+	channel := r.channel
+	if len(who) == 2 {
+		if !strings.HasPrefix(who[1], "#") {
+			return errors.New("expected #channel after the user name")
+		}
+		channel = strings.TrimPrefix(who[1], "#")
+	}
+	user := r.userID(who[0])
+
+	text = slack.EscapeText(text)
+	text = mentionRe.ReplaceAllStringFunc(text, func(m string) string {
+		name := strings.TrimPrefix(m, "@")
+		if name == "lab-bot" {
+			return "<@" + botUserID + ">"
+		}
+		return "<@" + r.userID(name) + ">"
+	})
+	if !strings.Contains(text, "<@"+botUserID+">") {
+		return nil
+	}
+
+	r.messages++
+	ev := &slackevents.AppMentionEvent{
+		User:      user,
+		Text:      text,
+		Channel:   channel,
+		TimeStamp: fmt.Sprintf("%010d.000100", r.messages),
+	}
+	if c, ok := slack.HandleMention(r.messenger, botUserID, ev); ok {
+		r.handler.Dispatch(c)
+	}
+	return nil
+}
+
+func (r *runner) directive(fields []string) error {
+	switch fields[0] {
+	case "@clock":
+		if len(fields) < 2 {
+			return errors.New("usage: @clock <YYYY-MM-DD HH:MM>")
+		}
+		t, err := parseTime(strings.Join(fields[1:], " "))
+		if err != nil {
+			return err
+		}
+		return r.advanceTo(t)
+	case "@advance":
+		if len(fields) != 2 {
+			return errors.New("usage: @advance <duration>")
+		}
+		d, err := parseDuration(fields[1])
+		if err != nil {
+			return err
+		}
+		return r.advanceTo(r.clock.Now().Add(d))
+	case "@channel":
+		if len(fields) != 2 {
+			return errors.New("usage: @channel <name>")
+		}
+		r.channel = strings.TrimPrefix(fields[1], "#")
+		return nil
+	case "@device":
+		if len(fields) != 3 {
+			return errors.New("usage: @device <controller> <on|off|unreachable|reachable>")
+		}
+		device, ok := r.handler.Device(fields[1])
+		if !ok {
+			return errors.New("no controller " + fields[1])
+		}
+		sim, ok := device.(simulator)
+		if !ok {
+			return errors.New("the device of " + fields[1] + " can't be simulated, use the virtual driver")
+		}
+		return sim.Simulate(fields[2])
+	case "@restart":
+		// The jobs are built again on the same database, and the output of
+		// loading them is left out like at the start.
+		if err := r.load(); err != nil {
+			return err
+		}
+		r.messenger.Reset()
+		return nil
+	}
+	return errors.New("unknown directive " + fields[0])
+}
+
+const advanceWindow = time.Hour
+
+func (r *runner) advanceTo(t time.Time) error {
+	now := r.clock.Now()
+	if t.Before(now) {
+		return errors.New("cannot move the clock backwards")
+	}
+	// Running an event can create new ones, like an auto-off deadline after
+	// a scheduled power on, so the rest is listed again after each instant.
+	// Long jumps are listed an hour at a time, as there can be a lot of polls.
+	for now.Before(t) {
+		until := t
+		if until.Sub(now) > advanceWindow {
+			until = now.Add(advanceWindow)
+		}
+		events := r.handler.DueEvents(now, until)
+		if len(events) == 0 {
+			now = until
+			continue
+		}
+		now = events[0].At
+		r.clock.Set(now)
+		for _, e := range events {
+			if !e.At.Equal(now) {
+				break
+			}
+			e.Run()
+		}
+	}
+	r.clock.Set(t)
+	return nil
+}
+
+func (r *runner) userID(name string) string {
+	id := "U" + strings.ToUpper(name)
+	r.messenger.Users[id] = name
+	return id
+}
+
+func (r *runner) render(calls []slack.RecordedCall) (lines []string) {
+	kinds := map[string]string{
+		"PostMessage":   "bot",
+		"SendMessage":   "bot",
+		"React":         "react",
+		"PinMessage":    "pin",
+		"ModifyMessage": "edit",
+		"DeleteMessage": "delete",
+		"UploadFile":    "upload",
+	}
+	for _, call := range calls {
+		kind, ok := kinds[call.Method]
+		if !ok {
+			continue
+		}
+		if call.Channel != r.channel {
+			kind += " #" + call.Channel
+		}
+		if call.Method == "UploadFile" {
+			call.Text = filepath.Base(call.Text)
+		}
+		text := userIDRe.ReplaceAllStringFunc(call.Text, func(m string) string {
+			return "@" + r.messenger.GetUserName(userIDRe.FindStringSubmatch(m)[1])
+		})
+		for i, l := range strings.Split(text, "\n") {
+			if i == 0 {
+				lines = append(lines, strings.TrimRight(kind+": "+l, " \t"))
+			} else {
+				lines = append(lines, strings.TrimRight("  | "+l, " \t"))
+			}
+		}
+	}
+	return lines
+}
+
+func equalLines(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{timeLayout, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("cannot parse time " + s + ", use " + timeLayout)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,135 @@
+package harness
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A transcript is a plain-text conversation with the bot:
+//
+//	# comments and blank lines are ignored
+//	@clock 2026-01-05 07:55
+//	alice: @lab-bot coffee schedule on set 0 8 * * 1-5
+//	bot: _Successfully scheduled power on task._
+//	  | *Scheduled On*: At 08:00 AM, Monday through Friday
+//	pin: Coffee Machine Controller: off
+//	@clock 2026-01-05 08:00
+//	bot #lab-bot-channel: Turned on the coffee machine
+//
+// Lines starting with @ are directives, "<user>[ #channel]: <text>" lines are
+// messages from lab members, and every line after them up to the next input
+// is the output expected from the bot. "@device coffee off" switches a
+// virtual device by hand, as if someone pressed its button.
+
+var outputKinds = []string{"bot", "react", "pin", "edit", "delete", "upload"}
+
+type step struct {
+	line     int
+	leading  []string
+	input    string
+	expected []string
+}
+
+type transcript struct {
+	path     string
+	steps    []step
+	trailing []string
+}
+
+func parseTranscript(path string) (t *transcript, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t = &transcript{path: path}
+	var pending []string
+	var current *step
+	lineNum := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			pending = append(pending, line)
+
+		case strings.HasPrefix(trimmed, "|"):
+			if current == nil || len(current.expected) == 0 || len(pending) != 0 {
+				return nil, fmt.Errorf("%s:%d: continuation line without bot output", path, lineNum)
+			}
+			current.expected = append(current.expected, "  "+trimmed)
+
+		case isOutputLine(trimmed):
+			if current == nil {
+				return nil, fmt.Errorf("%s:%d: bot output before any input", path, lineNum)
+			}
+			if len(pending) != 0 {
+				return nil, fmt.Errorf("%s:%d: bot output separated from its input", path, lineNum)
+			}
+			current.expected = append(current.expected, trimmed)
+
+		case strings.HasPrefix(trimmed, "@") || strings.Contains(trimmed, ":"):
+			t.steps = append(t.steps, step{
+				line:    lineNum,
+				leading: pending,
+				input:   trimmed,
+			})
+			current = &t.steps[len(t.steps)-1]
+			pending = nil
+
+		default:
+			return nil, fmt.Errorf("%s:%d: cannot parse line %q", path, lineNum, trimmed)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	t.trailing = pending
+
+	if len(t.steps) == 0 {
+		return nil, errors.New(path + ": transcript has no input lines")
+	}
+	return t, nil
+}
+
+func isOutputLine(line string) bool {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return false
+	}
+	who := strings.Fields(line[:i])
+	if len(who) == 0 {
+		return false
+	}
+	for _, kind := range outputKinds {
+		if who[0] == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *transcript) write(actual [][]string) error {
+	var b strings.Builder
+	for i, s := range t.steps {
+		for _, l := range s.leading {
+			b.WriteString(l + "\n")
+		}
+		b.WriteString(s.input + "\n")
+		for _, l := range actual[i] {
+			b.WriteString(l + "\n")
+		}
+	}
+	for _, l := range t.trailing {
+		b.WriteString(l + "\n")
+	}
+	return os.WriteFile(t.path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,40 @@
+package harness_test
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/harness"
+	"github.com/vishhvaan/lab-bot/logging"
+)
+
+// TestTranscripts replays the transcripts, so go test covers the jobs,
+// schedules and permissions they go through.
+func TestTranscripts(t *testing.T) {
+	logging.SetConsole(io.Discard)
+	log.SetOutput(io.Discard)
+
+	paths, err := filepath.Glob(filepath.Join("..", "transcripts", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no transcripts in ../transcripts")
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txt"), func(t *testing.T) {
+			result, err := harness.Run(path, "", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range result.Failures {
+				t.Error("\n" + f.String())
+			}
+		})
+	}
+}
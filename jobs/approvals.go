@@ -0,0 +1,395 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/config"
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/functions"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// Approvals hold back sensitive commands, like forcing a device or starting
+// an autoclave cycle, until a second member approves them. Requests are kept
+// in the database after they are decided, so they double as an audit trail
+// of who asked, who decided and how it ended.
+
+const (
+	defaultApprovalTimeout = 30 * time.Minute
+	approvalLogLength      = 10
+)
+
+const (
+	approvalPending   = "pending"
+	approvalApproved  = "approved"
+	approvalDenied    = "denied"
+	approvalWithdrawn = "withdrawn"
+	approvalExpired   = "expired"
+	approvalFailed    = "failed"
+)
+
+var approvalsPath = []string{"jobs", "approvals"}
+
+type approvalJob struct {
+	labJob
+	jh       *JobHandler
+	mu       sync.Mutex
+	requests map[string]*approvalRequest
+}
+
+type approvalRequest struct {
+	ID         string
+	Seq        int
+	Job        string
+	Subcommand string
+	Command    slack.CommandInfo
+	Requested  time.Time
+	Expires    time.Time
+	Status     string
+	Decider    string    `json:",omitempty"`
+	Decided    time.Time `json:",omitempty"`
+	timer      *time.Timer
+}
+
+func (r *approvalRequest) commandText() string {
+	return "`" + strings.Join(r.Command.Fields, " ") + "`"
+}
+
+func newApprovalJob(jh *JobHandler) *approvalJob {
+	return &approvalJob{
+		labJob: labJob{
+			name:      "Approvals",
+			keyword:   "approvals",
+			active:    true,
+			desc:      "Runs sensitive commands once a second member approves them",
+			logger:    jh.logger.WithFields(log.Fields{"jobtype": "bot", "job": "approvals"}),
+			messenger: jh.messenger,
+		},
+		jh:       jh,
+		requests: make(map[string]*approvalRequest),
+	}
+}
+
+func (aj *approvalJob) init() {
+	aj.labJob.init()
+
+	if !db.CheckBucketExists(approvalsPath) {
+		if err := db.CreateBucket(approvalsPath); err != nil {
+			aj.logger.WithError(err).Error("Cannot create the approvals bucket")
+			aj.active = false
+			return
+		}
+	}
+	aj.mu.Lock()
+	defer aj.mu.Unlock()
+	for _, r := range aj.load() {
+		if r.Status == approvalPending {
+			aj.requests[r.ID] = r
+			aj.arm(r)
+		}
+	}
+	aj.logger.Info(aj.name + " loaded")
+}
+
+// stop stops the expiry timers, which init arms again.
+func (aj *approvalJob) stop() {
+	aj.mu.Lock()
+	defer aj.mu.Unlock()
+	for id, r := range aj.requests {
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		delete(aj.requests, id)
+	}
+}
+
+func (aj *approvalJob) load() (requests []*approvalRequest) {
+	err := db.RunCallbackOnEachKey(approvalsPath, func(key []byte, value []byte) error {
+		r := &approvalRequest{}
+		if err := json.Unmarshal(value, r); err != nil {
+			return err
+		}
+		requests = append(requests, r)
+		return nil
+	})
+	if err != nil {
+		aj.logger.WithError(err).Error("Cannot load approval requests from db")
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Seq < requests[j].Seq
+	})
+	return requests
+}
+
+func (aj *approvalJob) save(r *approvalRequest) error {
+	buf, err := json.Marshal(r)
+	if err == nil {
+		err = db.AddValue(approvalsPath, r.ID, buf)
+	}
+	if err != nil {
+		aj.logger.WithError(err).WithField("id", r.ID).Error("Cannot save approval request to db")
+	}
+	return err
+}
+
+// request holds the command back and asks the lab for a second member.
+func (aj *approvalJob) request(job string, subcommand string, timeout time.Duration, c slack.CommandInfo) {
+	if !aj.active {
+		aj.messenger.PostMessage(c.Channel, "`"+strings.Join(c.Fields, " ")+"` needs approval, but the "+aj.name+" job is disabled")
+		return
+	}
+	aj.mu.Lock()
+	defer aj.mu.Unlock()
+
+	idNum, err := db.IncrementBucketInteger(approvalsPath)
+	if err != nil {
+		aj.logger.WithError(err).Error("Cannot get an ID for the approval request")
+		aj.messenger.PostMessage(c.Channel, "Couldn't save the request for approval, try again")
+		return
+	}
+	now := functions.Now()
+	r := &approvalRequest{
+		ID:         functions.SHA256Sum(strconv.Itoa(idNum)+"approval", controllerIDLen),
+		Seq:        idNum,
+		Job:        job,
+		Subcommand: subcommand,
+		Command:    c,
+		Requested:  now,
+		Expires:    now.Add(timeout),
+		Status:     approvalPending,
+	}
+	if aj.save(r) != nil {
+		aj.messenger.PostMessage(c.Channel, "Couldn't save the request for approval, try again")
+		return
+	}
+	aj.requests[r.ID] = r
+	aj.arm(r)
+
+	aj.audit(r).Info("Approval requested")
+	aj.messenger.PostMessage(c.Channel, "<@"+c.User+"> wants to run "+r.commandText()+", which needs a second member's approval.\n"+
+		"`@lab-bot approve "+r.ID+"` or `@lab-bot deny "+r.ID+"` before "+formatClock(r.Expires))
+}
+
+func (aj *approvalJob) commandProcessor(c slack.CommandInfo) {
+	if !aj.active {
+		aj.messenger.PostMessage(c.Channel, "The "+aj.name+" job is disabled")
+		return
+	}
+	switch strings.ToLower(c.Fields[0]) {
+	case "approve":
+		aj.runCommand(aj.decisions(approvalApproved), c)
+	case "deny":
+		aj.runCommand(aj.decisions(approvalDenied), c)
+	default:
+		aj.runCommand(aj.commands(), c)
+	}
+}
+
+func (aj *approvalJob) commands() commandSet {
+	return commandSet{
+		{desc: "Lists the requests waiting for approval, `approve <id>` or `deny <id>` decides on one", run: aj.list},
+		{name: "list", desc: "Lists the requests waiting for approval", run: aj.list},
+		{name: "log", args: []argSpec{number("n").opt()}, desc: "Shows the latest requests and how they ended", run: aj.log},
+	}
+}
+
+// decisions are the commands of the approve and deny aliases.
+func (aj *approvalJob) decisions(status string) commandSet {
+	return commandSet{
+		{args: []argSpec{word("id")}, desc: "Decides on a request waiting for approval", run: func(c slack.CommandInfo, a args) {
+			aj.decide(c, a.word("id"), status)
+		}},
+	}
+}
+
+// decide approves or denies a pending request. Only a member who may run
+// the command themselves can decide, and never the one who asked, though
+// they can deny their own request to withdraw it.
+func (aj *approvalJob) decide(c slack.CommandInfo, id string, status string) {
+	aj.mu.Lock()
+	r, ok := aj.requests[id]
+	if !ok {
+		aj.mu.Unlock()
+		aj.errorMsg(c.Fields, c.Channel, "There is no pending request `"+id+"`, `approvals` lists them")
+		return
+	}
+	if err := aj.authorize(r, c.User, status); err != nil {
+		aj.mu.Unlock()
+		aj.audit(r).WithField("decider", c.User).WithError(err).Warn("Approval refused")
+		aj.messenger.PostMessage(c.Channel, "Sorry, "+err.Error()+".")
+		return
+	}
+	if status == approvalDenied && c.User == r.Command.User {
+		status = approvalWithdrawn
+	}
+	aj.close(r, status, c.User)
+	aj.mu.Unlock()
+
+	message := "_<@" + c.User + "> " + status + " " + r.commandText() + " from <@" + r.Command.User + ">._"
+	if status == approvalWithdrawn {
+		message = "_<@" + c.User + "> withdrew " + r.commandText() + "._"
+	}
+	aj.messenger.PostMessage(c.Channel, message)
+	if status != approvalApproved {
+		return
+	}
+
+	if _, ok := aj.jh.jobs[r.Job]; !ok {
+		aj.mu.Lock()
+		r.Status = approvalFailed
+		aj.save(r)
+		aj.mu.Unlock()
+		aj.audit(r).Error("Approved command has no job to run it")
+		aj.messenger.PostMessage(r.Command.Channel, "Couldn't run "+r.commandText()+", its job isn't loaded")
+		return
+	}
+	aj.jh.runApproved(r.Job, r.Command)
+}
+
+func (aj *approvalJob) authorize(r *approvalRequest, user string, status string) error {
+	if user == r.Command.User {
+		if status == approvalApproved {
+			return errors.New("you can't approve your own request, someone else has to")
+		}
+		return nil
+	}
+	if _, ok := config.MemberByID(user); !ok {
+		return errors.New("only lab members can decide on requests, and you aren't in the members list")
+	}
+	as := r.Command
+	as.User = user
+	if aj.jh.check(r.Job, as) != "" {
+		return errors.New("you can't run " + r.commandText() + " yourself, so you can't decide on it")
+	}
+	return nil
+}
+
+// close records the outcome of a request; callers hold the lock.
+func (aj *approvalJob) close(r *approvalRequest, status string, decider string) {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	delete(aj.requests, r.ID)
+	r.Status = status
+	r.Decider = decider
+	r.Decided = functions.Now()
+	aj.save(r)
+	aj.audit(r).Info("Approval request " + status)
+}
+
+// arm sets a timer to expire the request, or expires it right away if it is
+// due.
+func (aj *approvalJob) arm(r *approvalRequest) {
+	wait := r.Expires.Sub(functions.Now())
+	if wait <= 0 {
+		aj.expire(r)
+		return
+	}
+	r.timer = functions.AfterFunc(wait, func() {
+		aj.mu.Lock()
+		defer aj.mu.Unlock()
+		aj.expire(r)
+	})
+}
+
+func (aj *approvalJob) expire(r *approvalRequest) {
+	if _, ok := aj.requests[r.ID]; !ok {
+		return
+	}
+	aj.close(r, approvalExpired, "")
+	aj.messenger.PostMessage(r.Command.Channel, "_"+r.commandText()+" from <@"+r.Command.User+"> expired without approval._")
+}
+
+func (aj *approvalJob) dueEvents(from time.Time, to time.Time) (events []dueEvent) {
+	aj.mu.Lock()
+	defer aj.mu.Unlock()
+	ids := functions.GetKeys(aj.requests)
+	// requests that expire together do in the order they were made
+	sort.Slice(ids, func(i, j int) bool {
+		return aj.requests[ids[i]].Seq < aj.requests[ids[j]].Seq
+	})
+	for _, id := range ids {
+		r := aj.requests[id]
+		if r.Expires.After(from) && !r.Expires.After(to) {
+			events = append(events, dueEvent{at: r.Expires, run: func() {
+				aj.mu.Lock()
+				defer aj.mu.Unlock()
+				aj.expire(r)
+			}})
+		}
+	}
+	return events
+}
+
+func (aj *approvalJob) list(c slack.CommandInfo, a args) {
+	aj.mu.Lock()
+	var pending []*approvalRequest
+	for _, r := range aj.requests {
+		pending = append(pending, r)
+	}
+	aj.mu.Unlock()
+	if len(pending) == 0 {
+		aj.messenger.PostMessage(c.Channel, "There are no requests waiting for approval")
+		return
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Seq < pending[j].Seq
+	})
+	lines := []string{"*Waiting for approval*"}
+	for _, r := range pending {
+		lines = append(lines, "`"+r.ID+"` "+r.commandText()+" from <@"+r.Command.User+">, until "+formatClock(r.Expires))
+	}
+	aj.messenger.PostMessage(c.Channel, strings.Join(lines, "\n"))
+}
+
+// log shows the latest requests and how they ended.
+func (aj *approvalJob) log(c slack.CommandInfo, a args) {
+	n := approvalLogLength
+	if a.has("n") {
+		if n = a.number("n"); n < 1 {
+			aj.errorMsg(c.Fields, c.Channel, "How many? Like `approvals log 20`")
+			return
+		}
+	}
+	aj.mu.Lock()
+	requests := aj.load()
+	aj.mu.Unlock()
+	if len(requests) == 0 {
+		aj.messenger.PostMessage(c.Channel, "No commands have asked for approval yet")
+		return
+	}
+	if len(requests) > n {
+		requests = requests[len(requests)-n:]
+	}
+	lines := []string{"*Approval log*"}
+	for _, r := range requests {
+		line := "`" + r.Requested.Format("Mon Jan 2 3:04 PM") + "` " + r.commandText() + " from <@" + r.Command.User + ">: " + r.Status
+		if r.Decider != "" && r.Status != approvalWithdrawn {
+			line += " by <@" + r.Decider + ">"
+		}
+		lines = append(lines, line)
+	}
+	aj.messenger.PostMessage(c.Channel, strings.Join(lines, "\n"))
+}
+
+func (aj *approvalJob) audit(r *approvalRequest) *log.Entry {
+	return aj.logger.WithFields(log.Fields{
+		"id":        r.ID,
+		"command":   strings.Join(r.Command.Fields, " "),
+		"requester": r.Command.User,
+		"status":    r.Status,
+	})
+}
+
+func (aj *approvalJob) errorMsg(fields []string, channel string, message string) {
+	go aj.logger.WithField("fields", fields).Warn(message)
+	aj.messenger.PostMessage(channel, message)
+}
@@ -0,0 +1,339 @@
+package jobs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/config"
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/files"
+	"github.com/vishhvaan/lab-bot/functions"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// The audit log keeps every command the bot is given, and every change it
+// makes because of one, like a device switched or a schedule removed, so
+// admins can look back at who did what from Slack.
+
+const (
+	auditShown     = 15
+	auditRetention = 365 * 24 * time.Hour
+
+	auditCommand = "command"
+)
+
+const (
+	resultDone     = "done"
+	resultUnknown  = "unknown command"
+	resultDenied   = "denied"
+	resultApproval = "waiting for approval"
+)
+
+var auditPath = []string{"audit"}
+
+type auditRecord struct {
+	At       time.Time
+	Kind     string
+	User     string `json:",omitempty"`
+	Channel  string `json:",omitempty"`
+	Job      string
+	Args     string
+	Result   string
+	Duration time.Duration `json:",omitempty"`
+}
+
+// recordAudit adds a record to the audit log, or replaces the one at key
+// when it is given.
+func recordAudit(logger *log.Entry, key string, r auditRecord) string {
+	buf, err := json.Marshal(r)
+	if err == nil && !db.CheckBucketExists(auditPath) {
+		err = db.CreateBucket(auditPath)
+	}
+	if err == nil && key == "" {
+		var seq int
+		seq, err = db.IncrementBucketInteger(auditPath)
+		key = fmt.Sprintf("%016d", seq)
+	}
+	if err == nil {
+		err = db.AddValue(auditPath, key, buf)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Cannot add a record to the audit log")
+	}
+	return key
+}
+
+// audit records a change a job made for a command, and the error it ended in.
+func (lj *labJob) audit(c slack.CommandInfo, kind string, args string, err error) {
+	result := resultDone
+	if err != nil {
+		result = err.Error()
+	}
+	recordAudit(lj.logger, "", auditRecord{
+		At:      functions.Now(),
+		Kind:    kind,
+		User:    c.User,
+		Channel: c.Channel,
+		Job:     keywordKey(lj.keyword),
+		Args:    args,
+		Result:  result,
+	})
+}
+
+type auditJob struct {
+	labJob
+	jh *JobHandler
+}
+
+type auditFilter struct {
+	user  string
+	job   string
+	since time.Time
+}
+
+func newAuditJob(jh *JobHandler) *auditJob {
+	return &auditJob{
+		labJob: labJob{
+			name:      "Audit Log",
+			keyword:   "audit",
+			active:    true,
+			desc:      "Shows who ran which commands and what they changed",
+			logger:    jh.logger.WithFields(log.Fields{"jobtype": "bot", "job": "audit"}),
+			messenger: jh.messenger,
+		},
+		jh: jh,
+	}
+}
+
+func (aj *auditJob) init() {
+	aj.labJob.init()
+	aj.prune()
+	aj.logger.Info(aj.name + " loaded")
+}
+
+// prune drops the records older than auditRetention.
+func (aj *auditJob) prune() {
+	if !db.CheckBucketExists(auditPath) {
+		return
+	}
+	cutoff := functions.Now().Add(-auditRetention)
+	var old []string
+	err := db.RunCallbackOnEachKey(auditPath, func(key []byte, value []byte) error {
+		var r auditRecord
+		if err := json.Unmarshal(value, &r); err != nil {
+			return err
+		}
+		if r.At.Before(cutoff) {
+			old = append(old, string(key))
+		}
+		return nil
+	})
+	for _, key := range old {
+		if err == nil {
+			err = db.DeleteValue(auditPath, key)
+		}
+	}
+	if err != nil {
+		aj.logger.WithError(err).Error("Cannot prune the audit log")
+	}
+}
+
+func (aj *auditJob) requiredRoles() map[string][]string {
+	return map[string][]string{
+		"": {adminRole},
+	}
+}
+
+func (aj *auditJob) commands() commandSet {
+	return commandSet{
+		{args: []argSpec{text("filters").opt()},
+			desc: "Shows the latest records, of a member or job and since a time like 7d or 2026-06-01 if given", run: aj.show},
+		{name: "export", args: []argSpec{text("filters").opt()}, desc: "Uploads the records as a CSV file", run: aj.exportRecords},
+	}
+}
+
+func (aj *auditJob) commandProcessor(c slack.CommandInfo) {
+	if !aj.active {
+		aj.messenger.PostMessage(c.Channel, "The "+aj.name+" job is disabled")
+		return
+	}
+	aj.runCommand(aj.commands(), c)
+}
+
+func (aj *auditJob) show(c slack.CommandInfo, a args) {
+	aj.report(c, a, false)
+}
+
+func (aj *auditJob) exportRecords(c slack.CommandInfo, a args) {
+	aj.report(c, a, true)
+}
+
+func (aj *auditJob) report(c slack.CommandInfo, a args, export bool) {
+	filter, err := aj.parseFilter(strings.Fields(a.word("filters")))
+	if err != nil {
+		aj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	records, err := aj.query(filter)
+	if err != nil {
+		aj.errorMsg(c.Fields, c.Channel, "Couldn't read the audit log")
+		return
+	}
+	if len(records) == 0 {
+		aj.messenger.PostMessage(c.Channel, "There is nothing in the audit log for that")
+		return
+	}
+	if export {
+		aj.export(c, records)
+		return
+	}
+
+	header := "*Audit log*"
+	if len(records) > auditShown {
+		header += " (last " + strconv.Itoa(auditShown) + " of " + strconv.Itoa(len(records)) + ", `audit export` has them all)"
+		records = records[len(records)-auditShown:]
+	}
+	lines := []string{header}
+	for _, r := range records {
+		lines = append(lines, describeAuditRecord(r))
+	}
+	aj.messenger.PostMessage(c.Channel, strings.Join(lines, "\n"))
+}
+
+// parseFilter reads "[user|job] [since]" in any order.
+func (aj *auditJob) parseFilter(args []string) (filter auditFilter, err error) {
+	now := functions.Now()
+	for _, arg := range args {
+		if userID, ok := mentionedUser(arg); ok {
+			filter.user = userID
+			continue
+		}
+		if member, ok := config.Members[strings.ToLower(arg)]; ok {
+			filter.user = member.UserID
+			continue
+		}
+		if key, ok := aj.jh.find(arg); ok {
+			filter.job = key
+			continue
+		}
+		if since, ok := parseSince(arg, now); ok {
+			filter.since = since
+			continue
+		}
+		return filter, fmt.Errorf("I don't know %s, give a member, a job or how far back to look like 7d or 2026-06-01", arg)
+	}
+	return filter, nil
+}
+
+func mentionedUser(text string) (userID string, ok bool) {
+	if strings.HasPrefix(text, "<@") && strings.HasSuffix(text, ">") {
+		return strings.TrimSuffix(strings.TrimPrefix(text, "<@"), ">"), true
+	}
+	return "", false
+}
+
+// parseSince reads how far back to look: a duration like 12h, 7d or 2w, or
+// a day like 2026-06-01, today or yesterday.
+func parseSince(text string, now time.Time) (since time.Time, ok bool) {
+	text = strings.ToLower(text)
+	if d, err := time.ParseDuration(text); err == nil && d > 0 {
+		return now.Add(-d), true
+	}
+	days := map[string]int{"d": 1, "w": 7}
+	if n, err := strconv.Atoi(strings.TrimRight(text, "dw")); err == nil && n > 0 && len(text) > 1 {
+		if per, ok := days[text[len(text)-1:]]; ok {
+			return now.AddDate(0, 0, -n*per), true
+		}
+	}
+	if text == "yesterday" {
+		text = "today"
+		now = now.AddDate(0, 0, -1)
+	}
+	if text == "today" || strings.Count(text, "-") == 2 {
+		day, err := functions.ParseDay(text, now)
+		return day, err == nil
+	}
+	return since, false
+}
+
+func (aj *auditJob) query(filter auditFilter) (records []auditRecord, err error) {
+	if !db.CheckBucketExists(auditPath) {
+		return nil, nil
+	}
+	err = db.RunCallbackOnEachKey(auditPath, func(key []byte, value []byte) error {
+		var r auditRecord
+		if err := json.Unmarshal(value, &r); err != nil {
+			return err
+		}
+		if (filter.user == "" || r.User == filter.user) &&
+			(filter.job == "" || r.Job == filter.job) &&
+			!r.At.Before(filter.since) {
+			records = append(records, r)
+		}
+		return nil
+	})
+	return records, err
+}
+
+func describeAuditRecord(r auditRecord) string {
+	who := "the bot"
+	if r.User != "" {
+		who = "<@" + r.User + ">"
+	}
+	line := "`" + r.At.Format("Mon Jan 2 3:04 PM") + "` " + who + " "
+	if r.Kind == auditCommand {
+		line += "`" + strings.TrimSpace(r.Job+" "+r.Args) + "`"
+	} else {
+		what := r.Job
+		if r.Kind != r.Job {
+			what += " " + r.Kind
+		}
+		line += "_" + what + " " + r.Args + "_"
+	}
+	if r.Result != resultDone {
+		line += ": " + r.Result
+	}
+	return line
+}
+
+// export uploads the records as a CSV file.
+func (aj *auditJob) export(c slack.CommandInfo, records []auditRecord) {
+	path := filepath.Join(os.TempDir(), "audit-"+functions.Now().Format("20060102-150405")+".csv")
+	file, err := os.Create(path)
+	if err == nil {
+		w := csv.NewWriter(file)
+		w.Write([]string{"time", "kind", "user", "channel", "job", "args", "result", "duration_ms"})
+		for _, r := range records {
+			w.Write([]string{
+				r.At.Format(time.RFC3339), r.Kind, r.User, r.Channel, r.Job, r.Args, r.Result,
+				strconv.FormatInt(r.Duration.Milliseconds(), 10),
+			})
+		}
+		w.Flush()
+		err = w.Error()
+		if e := file.Close(); err == nil {
+			err = e
+		}
+	}
+	if err == nil {
+		err = aj.messenger.UploadFile(c.Channel, path, "Audit log")
+	}
+	files.DeleteFile(path)
+	if err != nil {
+		aj.logger.WithError(err).Error("Cannot export the audit log")
+		aj.messenger.PostMessage(c.Channel, "Couldn't export the audit log")
+	}
+}
+
+func (aj *auditJob) errorMsg(fields []string, channel string, message string) {
+	go aj.logger.WithField("fields", fields).Warn(message)
+	aj.messenger.PostMessage(channel, message)
+}
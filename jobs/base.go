@@ -1,10 +1,15 @@
 package jobs
 
 import (
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/vishhvaan/lab-bot/config"
 	"github.com/vishhvaan/lab-bot/functions"
 	"github.com/vishhvaan/lab-bot/logging"
 	"github.com/vishhvaan/lab-bot/scheduling"
@@ -12,106 +17,311 @@ import (
 )
 
 type labJob struct {
-	name    string
-	keyword string
-	active  bool
-	desc    string
-	logger  *log.Entry
+	name      string
+	keyword   string
+	active    bool
+	desc      string
+	logger    *log.Entry
+	messenger slack.Messenger
+	holidays  *scheduling.Calendar
 	job
 }
 
-type action func(c slack.CommandInfo)
-
 type job interface {
 	init()
 	enable()
 	disable()
+	isActive() bool
 	commandProcessor(c slack.CommandInfo)
 }
 
 type JobHandler struct {
-	jobs   map[string]job
-	logger *log.Entry
+	jobs      map[string]job
+	keywords  map[string]string
+	access    map[string]*access
+	failed    map[string]bool
+	approvals *approvalJob
+	messenger slack.Messenger
+	logger    *log.Entry
 }
 
-func CreateHandler() (jh *JobHandler) {
-	jobs := make(map[string]job)
-
+// CreateHandler builds the jobs enabled in the jobs config. It fails on an
+// unknown job type, a bad job option or a keyword used twice.
+func CreateHandler(messenger slack.Messenger, jobsConfig config.JobsConfig) (jh *JobHandler, err error) {
 	jobLogger := logging.CreateNewLogger("jobhandler", "jobhandler")
 
-	jobs["paper"] = &paperUploaderJob{
-		labJob: labJob{
-			name:    "Paper Uploader",
-			keyword: "paper",
-			active:  true,
-			desc:    "Uploads papers downloaded from the scidownl utility",
-			logger: jobLogger.WithFields(log.Fields{
-				"jobtype": "uploader",
-				"job":     "paperUploader",
-			}),
-		},
-	}
-
-	jobs["&gt;"] = &openAIBot{
-		labJob: labJob{
-			name:    "OpenAI Bot",
-			keyword: ">",
-			active:  true,
-			desc:    "Passes queries to the OpenAI API and returns top completion",
-			logger: jobLogger.WithFields(log.Fields{
-				"jobtype": "bot",
-				"job":     "openAIBot",
-			}),
-		},
-	}
-
-	jobs["birthday"] = &birthdayJob{
-		labJob: labJob{
-			name:    "Birthday Bot",
-			keyword: "birthday",
-			active:  true,
-			desc:    "Monitors, alerts, and records member birthdays",
-			logger: jobLogger.WithFields(log.Fields{
-				"jobtype": "bot",
-				"job":     "birthdayBot",
-			}),
-		},
-		scheduling: scheduling.BirthdaySchedule{
-			BirthdayMessageChannel: "lab-bot-channel-test",
-			CronExp:                "0 8 * * *",
-			Logger: jobLogger.WithFields(log.Fields{
-				"jobtype": "bot",
-				"job":     "birthdayBot",
-				"task":    "scheduling",
-			}),
-		},
-	}
-
-	return &JobHandler{
-		jobs:   jobs,
-		logger: jobLogger,
+	jh = &JobHandler{
+		jobs:      make(map[string]job),
+		keywords:  make(map[string]string),
+		access:    make(map[string]*access),
+		failed:    make(map[string]bool),
+		messenger: messenger,
+		logger:    jobLogger,
+	}
+	holidays := scheduling.NewCalendar(jobLogger.WithField("task", "holidays"))
+
+	for i, jc := range jobsConfig.Jobs {
+		if !jc.IsEnabled() {
+			jobLogger.WithField("keyword", jc.Keyword).Info("Skipping disabled job")
+			continue
+		}
+
+		j, err := buildJob(jc, messenger, jobLogger, holidays)
+		if err == nil {
+			err = jh.addJob(jc.Keyword, jc.Aliases, j)
+		}
+		if err == nil {
+			err = jh.restrict(jc.Keyword, nil, jc.Permissions, jc.Approval)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("job %d (%s %s): %w", i+1, jc.Type, jc.Keyword, err)
+		}
+	}
+
+	for i, cc := range jobsConfig.Controllers {
+		if !cc.IsEnabled() {
+			jobLogger.WithField("keyword", cc.Keyword).Info("Skipping disabled controller")
+			continue
+		}
+
+		cj, err := buildController(cc, messenger, jobLogger, holidays)
+		if err == nil {
+			err = jh.addJob(cc.Keyword, cc.Aliases, cj)
+		}
+		if err == nil {
+			err = jh.restrict(cc.Keyword, cc.Allow, cc.Permissions, cc.Approval)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("controller %d (%s): %w", i+1, cc.Keyword, err)
+		}
+	}
+
+	for i, gc := range jobsConfig.Groups {
+		members, err := jh.controllers(gc.Controllers, jobsConfig.Controllers)
+		var cj *controllerJob
+		if err == nil {
+			cj, err = buildGroup(gc, members, messenger, jobLogger, holidays)
+		}
+		if err == nil {
+			err = jh.addJob(gc.Keyword, gc.Aliases, cj)
+		}
+		if err == nil {
+			err = jh.restrict(gc.Keyword, gc.Allow, gc.Permissions, gc.Approval)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("group %d (%s): %w", i+1, gc.Keyword, err)
+		}
+	}
+
+	if err = jh.buildInterlocks(jobsConfig.Interlocks, jobsConfig.Controllers); err != nil {
+		return nil, err
+	}
+
+	if len(jobsConfig.Scenes) != 0 {
+		sj, err := buildScenes(jobsConfig.Scenes, jh, jobsConfig.Controllers)
+		if err == nil {
+			err = jh.addJob(sj.keyword, []string{"scenes"}, sj)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	aj := newAuditJob(jh)
+	if err = jh.addJob(aj.keyword, nil, aj); err != nil {
+		return nil, err
+	}
+	hj := newHelpJob(jh)
+	if err = jh.addJob(hj.keyword, nil, hj); err != nil {
+		return nil, err
+	}
+	jj := newJobsJob(jh)
+	if err = jh.addJob(jj.keyword, nil, jj); err != nil {
+		return nil, err
+	}
+
+	for _, a := range jh.access {
+		if len(a.approval) > 0 {
+			jh.approvals = newApprovalJob(jh)
+			if err = jh.addJob(jh.approvals.keyword, []string{"approve", "deny"}, jh.approvals); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	return jh, nil
+}
+
+func (jh *JobHandler) addJob(keyword string, aliases []string, j job) error {
+	key := keywordKey(keyword)
+	if _, exists := jh.keywords[key]; exists {
+		return errors.New("keyword \"" + keyword + "\" is used by another job")
+	}
+	for _, alias := range aliases {
+		if _, exists := jh.keywords[keywordKey(alias)]; exists || keywordKey(alias) == key {
+			return errors.New("alias \"" + alias + "\" is used by another job")
+		}
+	}
+
+	jh.jobs[key] = j
+	jh.access[key] = newAccess(j)
+	jh.keywords[key] = key
+	for _, alias := range aliases {
+		jh.keywords[keywordKey(alias)] = key
 	}
+	return nil
 }
 
 func (jh *JobHandler) InitJobs() {
 	for job := range jh.jobs {
-		jh.jobs[job].init()
-		switch j := jh.jobs[job].(type) {
-		case *controllerJob:
-			j.customInit()
+		jh.load(job)
+	}
+	jh.applyDisabled()
+}
+
+// StopJobs stops the schedulers, timers and pollers of the jobs, like before
+// they are built again on the same database.
+func (jh *JobHandler) StopJobs() {
+	for _, j := range jh.jobs {
+		if sj, ok := j.(stoppableJob); ok {
+			sj.stop()
 		}
 	}
 }
 
+// load runs the init of a job, and notes whether it failed to load.
+func (jh *JobHandler) load(job string) {
+	jh.jobs[job].init()
+	jh.failed[job] = !jh.jobs[job].isActive()
+}
+
+// find looks up a job by a keyword or alias typed in a command, which comes
+// escaped like the keys already are.
+func (jh *JobHandler) find(typed string) (key string, ok bool) {
+	key, ok = jh.keywords[strings.ToLower(typed)]
+	return key, ok
+}
+
 func (jh *JobHandler) CommandReceiver() {
 	for command := range slack.CommandChan {
-		k := strings.ToLower(command.Fields[0])
-		if functions.Contains(functions.GetKeys(jh.jobs), k) {
-			jh.jobs[k].commandProcessor(command)
-		} else {
-			slack.PostMessage(command.Channel, "I couldn't find a response to your command.")
+		jh.Dispatch(command)
+	}
+}
+
+func (jh *JobHandler) Dispatch(command slack.CommandInfo) {
+	jh.audited(command, func(record *auditRecord) {
+		job, ok := jh.find(record.Job)
+		if !ok {
+			record.Result = resultUnknown
+			jh.messenger.PostMessage(command.Channel, "I couldn't find a response to your command.")
+			return
+		}
+		record.Job = job
+		if denied := jh.check(job, command); denied != "" {
+			record.Result = resultDenied
+			jh.denied(command, denied)
+			return
+		}
+		if subcommand, timeout := jh.needsApproval(job, command); subcommand != "" {
+			record.Result = resultApproval
+			jh.approvals.request(job, subcommand, timeout, command)
+			return
+		}
+		jh.jobs[job].commandProcessor(command)
+	})
+}
+
+// runApproved runs a command a second member approved, recorded like the
+// commands Dispatch runs.
+func (jh *JobHandler) runApproved(job string, command slack.CommandInfo) {
+	jh.audited(command, func(record *auditRecord) {
+		record.Job = job
+		jh.jobs[job].commandProcessor(command)
+	})
+}
+
+// audited records a command in the audit log before it runs, so it comes
+// before the changes it makes, and again with how it ended.
+func (jh *JobHandler) audited(command slack.CommandInfo, run func(record *auditRecord)) {
+	start := time.Now()
+	record := auditRecord{
+		At:      functions.Now(),
+		Kind:    auditCommand,
+		User:    command.User,
+		Channel: command.Channel,
+		Job:     strings.ToLower(command.Fields[0]),
+		Args:    strings.Join(command.Fields[1:], " "),
+		Result:  resultDone,
+	}
+	key := recordAudit(jh.logger, "", record)
+	defer func() {
+		record.Duration = time.Since(start)
+		recordAudit(jh.logger, key, record)
+	}()
+	run(&record)
+}
+
+// Device returns the driver behind a controller, so tests can stand in for
+// someone using the device by hand.
+func (jh *JobHandler) Device(keyword string) (device any, ok bool) {
+	key, ok := jh.keywords[keywordKey(keyword)]
+	if !ok {
+		return nil, false
+	}
+	cj, ok := jh.jobs[key].(*controllerJob)
+	if !ok {
+		return nil, false
+	}
+	return cj.device, true
+}
+
+type DueEvent struct {
+	At  time.Time
+	Run func()
+}
+
+// dueEvent is work due at a time. A command goes through Dispatch like the
+// schedulers send it, unless skip tells the run is skipped.
+type dueEvent struct {
+	at      time.Time
+	command *slack.CommandInfo
+	skip    func() bool
+	run     func()
+}
+
+type scheduledJob interface {
+	dueEvents(from time.Time, to time.Time) []dueEvent
+}
+
+// DueEvents lists the scheduled work that falls in (from, to], oldest first,
+// so a fake clock can replay it. Scheduled commands go through Dispatch.
+func (jh *JobHandler) DueEvents(from time.Time, to time.Time) (events []DueEvent) {
+	keys := functions.GetKeys(jh.jobs)
+	sort.Strings(keys)
+	for _, k := range keys {
+		sj, ok := jh.jobs[k].(scheduledJob)
+		if !ok {
+			continue
+		}
+		for _, e := range sj.dueEvents(from, to) {
+			run := e.run
+			if e.command != nil {
+				command, skip := *e.command, e.skip
+				run = func() {
+					if skip == nil || !skip() {
+						jh.Dispatch(command)
+					}
+				}
+			}
+			events = append(events, DueEvent{At: e.at, Run: run})
 		}
 	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+	return events
 }
 
 func (lj *labJob) init() {
@@ -131,15 +341,8 @@ func (lj *labJob) disable() {
 	lj.logger.Info("Disabled job " + lj.name)
 }
 
-func (lj *labJob) commandProcessor(c slack.CommandInfo) {}
-
-func commandCheck(c slack.CommandInfo, length int, l *log.Entry) bool {
-	if len(c.Fields) > length {
-		message := "Your command has more parameters than necessary"
-		go l.Info(message)
-		slack.PostMessage(c.Channel, message)
-		return false
-	} else {
-		return true
-	}
+func (lj *labJob) isActive() bool {
+	return lj.active
 }
+
+func (lj *labJob) commandProcessor(c slack.CommandInfo) {}
@@ -14,6 +14,8 @@ import (
 	"github.com/vishhvaan/lab-bot/slack"
 )
 
+const defaultBirthdayCron = "0 8 * * *"
+
 type birthdayJob struct {
 	labJob
 	dbPath     []string
@@ -33,37 +35,45 @@ func (bj *birthdayJob) init() {
 
 	if err == nil {
 		bj.logger.Info(bj.name + " loaded")
-		slack.Message(bj.name + " loaded. " + strconv.Itoa(numBirthdays) + " birthdays found.")
+		bj.messenger.Message(bj.name + " loaded. " + strconv.Itoa(numBirthdays) + " birthdays found.")
 	} else {
 		bj.logger.WithError(err).Error(bj.name + " cannot initialize")
 	}
 
 }
 
+func (bj *birthdayJob) commands() commandSet {
+	return commandSet{
+		{desc: "Shows your birthday on record", run: bj.birthdayStatus},
+		{name: "status", args: []argSpec{mention("user").opt()}, desc: "Shows the birthday on record of you or someone else", run: bj.birthdayStatus},
+		{name: "record", args: []argSpec{word("date"), mention("user").opt()}, flags: []string{"force"},
+			desc: "Records a birthday like 10-24 for you or someone else, force replaces the one on record", run: bj.recordBirthday},
+		{name: "delete", desc: "Deletes your birthday", run: bj.deleteBirthday},
+		{name: "upcoming", flags: []string{"force"}, desc: "Lists the birthdays coming up, force works them out again", run: bj.upcoming},
+	}
+}
+
 func (bj *birthdayJob) commandProcessor(c slack.CommandInfo) {
 	if bj.active {
-		birthdayActions := map[string]action{
-			"record":   bj.recordBirthday,
-			"delete":   bj.deleteBirthday,
-			"status":   bj.birthdayStatus,
-			"upcoming": bj.scheduling.UpcomingBirthdays,
-		}
-		if len(c.Fields) == 1 {
-			bj.birthdayStatus(c)
-		} else {
-			k := functions.GetKeys(birthdayActions)
-			subcommand := strings.ToLower(c.Fields[1])
-			if functions.Contains(k, subcommand) {
-				f := birthdayActions[subcommand]
-				f(c)
-			} else {
-				slack.PostMessage(c.Channel, "Wrong syntax, young padwan")
-				bj.logger.WithField("fields", c.Fields).Info("Wrong syntax for birthday")
-			}
-		}
+		bj.runCommand(bj.commands(), c)
 	} else {
-		slack.PostMessage(c.Channel, "The "+bj.name+" is disabled")
+		bj.messenger.PostMessage(c.Channel, "The "+bj.name+" is disabled")
+	}
+}
+
+func (bj *birthdayJob) stop() {
+	bj.scheduling.Stop()
+}
+
+func (bj *birthdayJob) dueEvents(from time.Time, to time.Time) (events []dueEvent) {
+	runs, err := bj.scheduling.DueRuns(from, to)
+	if err != nil {
+		bj.logger.WithError(err).Error("Cannot list due birthday checks")
+	}
+	for _, run := range runs {
+		events = append(events, dueEvent{at: run, run: bj.scheduling.Congratulate})
 	}
+	return events
 }
 
 // db organization birthdays/key = user, value = time.Time
@@ -84,7 +94,7 @@ func (bj *birthdayJob) checkCreateBucket() {
 
 func (bj *birthdayJob) errorMsg(c slack.CommandInfo, err error, message string) {
 	go bj.logger.WithField("fields", c.Fields).WithError(err).Warn(message)
-	slack.PostMessage(c.Channel, message)
+	bj.messenger.PostMessage(c.Channel, message)
 }
 
 func (bj *birthdayJob) numerateBirthdays() (numBirthdays int, err error) {
@@ -92,34 +102,15 @@ func (bj *birthdayJob) numerateBirthdays() (numBirthdays int, err error) {
 	return len(keys), err
 }
 
-// birthday status [@user]
-func (bj *birthdayJob) birthdayStatus(c slack.CommandInfo) {
-	isMention := func(tok string) bool {
-		return strings.HasPrefix(tok, "<@") && strings.HasSuffix(tok, ">")
-	}
-	mentionToID := func(tok string) string {
-		return strings.TrimSuffix(strings.TrimPrefix(tok, "<@"), ">")
-	}
+func (bj *birthdayJob) upcoming(c slack.CommandInfo, a args) {
+	bj.scheduling.UpcomingBirthdays(c, a.flag("force"))
+}
 
-	// parse tokens after “birthday status”
+// birthday status [@user]
+func (bj *birthdayJob) birthdayStatus(c slack.CommandInfo, a args) {
 	targetUser := c.User
-	mentionSeen := false
-
-	for _, tok := range c.Fields[2:] { // skip “birthday” “status”
-		if isMention(tok) {
-			if mentionSeen {
-				slack.SendMessage(c.Channel,
-					"please mention at most one user")
-				return
-			}
-			targetUser = mentionToID(tok)
-			mentionSeen = true
-			continue
-		}
-		// any other token is unexpected
-		slack.SendMessage(c.Channel,
-			"usage: birthday status [@user]")
-		return
+	if a.has("user") {
+		targetUser = a.word("user")
 	}
 
 	// fetch birthday from DB
@@ -130,10 +121,10 @@ func (bj *birthdayJob) birthdayStatus(c slack.CommandInfo) {
 	}
 	if b == nil {
 		if targetUser == c.User {
-			slack.SendMessage(c.Channel, "You have no birthday on record")
+			bj.messenger.SendMessage(c.Channel, "You have no birthday on record")
 		} else {
-			m := fmt.Sprintf("%s has no birthday on record", slack.GetUserName(targetUser))
-			slack.SendMessage(c.Channel, m)
+			m := fmt.Sprintf("%s has no birthday on record", bj.messenger.GetUserName(targetUser))
+			bj.messenger.SendMessage(c.Channel, m)
 
 		}
 		return
@@ -149,17 +140,17 @@ func (bj *birthdayJob) birthdayStatus(c slack.CommandInfo) {
 	display := bd.Format("January 2") // show only month-day, ignore stored year
 
 	if targetUser == c.User {
-		slack.SendMessage(c.Channel,
+		bj.messenger.SendMessage(c.Channel,
 			fmt.Sprintf("Your birthday on record is *%s*", display))
 	} else {
-		slack.SendMessage(c.Channel,
-			fmt.Sprintf("%s's birthday on record is *%s*", slack.GetUserName(targetUser), display))
+		bj.messenger.SendMessage(c.Channel,
+			fmt.Sprintf("%s's birthday on record is *%s*", bj.messenger.GetUserName(targetUser), display))
 	}
 }
 
 // record a birthday:  “birthday record 10-24 [@user] [force]”
-func (bj *birthdayJob) recordBirthday(c slack.CommandInfo) {
-	loc := time.Now().Location()
+func (bj *birthdayJob) recordBirthday(c slack.CommandInfo, a args) {
+	loc := functions.Now().Location()
 
 	parseMonthDay := func(s string) (time.Time, bool) {
 		var sep string
@@ -181,57 +172,15 @@ func (bj *birthdayJob) recordBirthday(c slack.CommandInfo) {
 			day < 1 || day > 31 {
 			return time.Time{}, false
 		}
-		return time.Date(time.Now().Year(), time.Month(month), day, 0, 0, 0, 0, loc), true
-	}
-
-	isMention := func(tok string) bool {
-		return strings.HasPrefix(tok, "<@") && strings.HasSuffix(tok, ">")
-	}
-	mentionToID := func(tok string) string {
-		return strings.TrimSuffix(strings.TrimPrefix(tok, "<@"), ">")
-	}
-
-	// parse tokens after “birthday record”
-	if len(c.Fields) < 3 {
-		slack.SendMessage(c.Channel,
-			"usage: birthday record <MM-DD | YYYY-MM-DD> [@user] [force]")
-		return
+		return time.Date(functions.Now().Year(), time.Month(month), day, 0, 0, 0, 0, loc), true
 	}
 
 	targetUser := c.User // default to caller
-	var dateToken string
-	force := false
-	mentionSeen := false
-
-	for _, tok := range c.Fields[2:] {
-		switch {
-		case tok == "force":
-			force = true
-
-		case isMention(tok):
-			if mentionSeen {
-				slack.SendMessage(c.Channel,
-					"Please mention at most one user. usage: birthday record <MM-DD | YYYY-MM-DD> [force]")
-				return
-			}
-			targetUser = mentionToID(tok)
-			mentionSeen = true
-
-		default: // assume this is the date string
-			if dateToken == "" {
-				dateToken = tok
-			} else {
-				slack.SendMessage(c.Channel,
-					"Too many date tokens; please supply only one. usage: birthday record <MM-DD | YYYY-MM-DD> [force]")
-				return
-			}
-		}
-	}
-
-	if dateToken == "" {
-		slack.SendMessage(c.Channel, "Birthday date is missing")
-		return
+	if a.has("user") {
+		targetUser = a.word("user")
 	}
+	dateToken := a.word("date")
+	force := a.flag("force")
 
 	// convert date string ➜ time.Time (midnight, current year)
 	var newBD time.Time
@@ -243,7 +192,7 @@ func (bj *birthdayJob) recordBirthday(c slack.CommandInfo) {
 		if err != nil {
 			go bj.logger.WithField("fields", c.Fields).
 				WithError(err).Warn("cannot parse date")
-			slack.PostMessage(c.Channel, "cannot parse date. usage: birthday record <MM-DD | YYYY-MM-DD> [force] -- "+err.Error())
+			bj.messenger.PostMessage(c.Channel, "cannot parse date. usage: birthday record <MM-DD | YYYY-MM-DD> [force] -- "+err.Error())
 			return
 		}
 	}
@@ -259,11 +208,13 @@ func (bj *birthdayJob) recordBirthday(c slack.CommandInfo) {
 	if b == nil || force {
 		// save / overwrite
 		byteBD, _ := newBD.MarshalJSON()
-		if err = db.AddValue(append(bj.dbPath, "records"), targetUser, byteBD); err != nil {
+		err = db.AddValue(append(bj.dbPath, "records"), targetUser, byteBD)
+		bj.audit(c, "birthday", "record <@"+targetUser+"> "+newBD.Format("2006-01-02"), err)
+		if err != nil {
 			bj.errorMsg(c, err, "cannot record birthday to database")
 			return
 		}
-		slack.React(c.TimeStamp, c.Channel, "tada")
+		bj.messenger.React(c.TimeStamp, c.Channel, "tada")
 		return
 	}
 
@@ -275,20 +226,14 @@ func (bj *birthdayJob) recordBirthday(c slack.CommandInfo) {
 	}
 
 	if oldBD.Month() == newBD.Month() && oldBD.Day() == newBD.Day() {
-		slack.SendMessage(c.Channel, "This birthday is already on record")
+		bj.messenger.SendMessage(c.Channel, "This birthday is already on record")
 	} else {
-		slack.SendMessage(c.Channel,
+		bj.messenger.SendMessage(c.Channel,
 			"A different birthday is already on record; delete it first or use the 'force' flag")
 	}
 }
 
-func (bj *birthdayJob) deleteBirthday(c slack.CommandInfo) {
-	if len(c.Fields) > 2 {
-		go bj.logger.WithField("fields", c.Fields).Warn("too many fields")
-		slack.SendMessage(c.Channel, "This birthday is already on record")
-		return
-	}
-
+func (bj *birthdayJob) deleteBirthday(c slack.CommandInfo, a args) {
 	b, err := db.ReadValue(append(bj.dbPath, "records"), c.User)
 	if err != nil {
 		bj.errorMsg(c, err, "cannot read existing birthday from db")
@@ -296,16 +241,17 @@ func (bj *birthdayJob) deleteBirthday(c slack.CommandInfo) {
 	}
 
 	if b == nil {
-		slack.SendMessage(c.Channel, "There is no birthday on record for you")
+		bj.messenger.SendMessage(c.Channel, "There is no birthday on record for you")
 		return
 	}
 
 	err = db.DeleteValue(append(bj.dbPath, "records"), c.User)
+	bj.audit(c, "birthday", "delete <@"+c.User+">", err)
 	if err != nil {
 		bj.errorMsg(c, err, "cannot delete birthday")
 		return
 	}
 
-	slack.SendMessage(c.Channel, "Birthday deleted!")
+	bj.messenger.SendMessage(c.Channel, "Birthday deleted!")
 
 }
@@ -0,0 +1,514 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// Jobs declare their commands with the arguments they take, so every
+// command is split, checked and answered with a usage line the same way,
+// and the help is written from the same declarations. Quoted text and JSON
+// stay in one piece, mentions are read as user IDs and durations as
+// time.Duration.
+
+type argKind int
+
+const (
+	wordArg argKind = iota
+	textArg
+	numberArg
+	durationArg
+	mentionArg
+)
+
+type argSpec struct {
+	name     string
+	kind     argKind
+	optional bool
+}
+
+func word(name string) argSpec     { return argSpec{name: name, kind: wordArg} }
+func text(name string) argSpec     { return argSpec{name: name, kind: textArg} }
+func number(name string) argSpec   { return argSpec{name: name, kind: numberArg} }
+func duration(name string) argSpec { return argSpec{name: name, kind: durationArg} }
+func mention(name string) argSpec  { return argSpec{name: name, kind: mentionArg} }
+
+func (a argSpec) opt() argSpec {
+	a.optional = true
+	return a
+}
+
+// command is a subcommand of a job; the one without a name runs when no
+// other matches. Flags are words like "force" that can go anywhere after it.
+// A command that sets a schedule names the one the schedule runs, which it
+// needs the permissions and approval of.
+type command struct {
+	name      string
+	args      []argSpec
+	flags     []string
+	schedules string
+	desc      string
+	run       func(c slack.CommandInfo, a args)
+}
+
+type commandSet []*command
+
+type args struct {
+	values map[string]any
+	flags  map[string]bool
+}
+
+func (a args) has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+// word returns a word, text or mention argument, or "" when it was left out.
+func (a args) word(name string) string {
+	s, _ := a.values[name].(string)
+	return s
+}
+
+func (a args) number(name string) int {
+	n, _ := a.values[name].(int)
+	return n
+}
+
+func (a args) duration(name string) time.Duration {
+	d, _ := a.values[name].(time.Duration)
+	return d
+}
+
+func (a args) flag(name string) bool {
+	return a.flags[name]
+}
+
+// token is a word of a command, and where it is in the command as typed.
+type token struct {
+	text       string
+	quoted     bool
+	start, end int
+}
+
+var quotePairs = map[rune]rune{'"': '"', '“': '”', '‘': '’'}
+var bracketPairs = map[rune]rune{'{': '}', '[': ']'}
+
+// tokenize splits a command into words at the same spaces strings.Fields
+// does, so the job sees the words the permissions were checked on. Text in
+// quotes, including the curly ones Slack types, is one word without its
+// quotes, and a JSON object or list is one word as it was typed.
+func tokenize(s string) (tokens []token, err error) {
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case quotePairs[r] != 0:
+			end := indexRune(runes[i+1:], quotePairs[r])
+			if end < 0 {
+				return nil, errors.New("a quote isn't closed")
+			}
+			tokens = append(tokens, token{text: string(runes[i+1 : i+1+end]), quoted: true, start: i, end: i + end + 2})
+			i += end + 2
+		case bracketPairs[r] != 0:
+			end, err := matchBracket(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: string(runes[i : end+1]), quoted: true, start: i, end: end + 1})
+			i = end + 1
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i]), start: start, end: i})
+		}
+	}
+	return tokens, nil
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchBracket finds the bracket closing the one at start, skipping over
+// strings in between.
+func matchBracket(runes []rune, start int) (end int, err error) {
+	var stack []rune
+	inString := false
+	for i := start; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inString && r == '\\':
+			i++
+		case r == '"' || r == '“' || r == '”':
+			inString = !inString
+		case inString:
+		case bracketPairs[r] != 0:
+			stack = append(stack, bracketPairs[r])
+		case len(stack) > 0 && r == stack[len(stack)-1]:
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("a bracket isn't closed")
+}
+
+func commandText(c slack.CommandInfo) string {
+	if c.Text != "" {
+		return c.Text
+	}
+	return strings.Join(c.Fields, " ")
+}
+
+// find picks the command with the longest name that the words start with,
+// and returns the words after it.
+func (cs commandSet) find(tokens []token) (found *command, rest []token) {
+	longest := -1
+	for _, cmd := range cs {
+		name := strings.Fields(cmd.name)
+		if len(name) <= longest || len(name) > len(tokens) {
+			continue
+		}
+		match := true
+		for i, w := range name {
+			if tokens[i].quoted || strings.ToLower(tokens[i].text) != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			found, rest, longest = cmd, tokens[len(name):], len(name)
+		}
+	}
+	return found, rest
+}
+
+// hasUnder tells whether there are commands named after the given one, like
+// "schedule pause" after "schedule".
+func (cs commandSet) hasUnder(name string) bool {
+	for _, cmd := range cs {
+		if strings.HasPrefix(cmd.name, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// names lists the words that can come after a command, like "on" and "off"
+// after "force", or the first words of the commands when it is "".
+func (cs commandSet) names(after string) (names []string) {
+	seen := make(map[string]bool)
+	for _, cmd := range cs {
+		name := cmd.name
+		if after != "" {
+			if !strings.HasPrefix(name, after+" ") {
+				continue
+			}
+			name = strings.TrimPrefix(name, after+" ")
+		}
+		if w := strings.Fields(name); len(w) > 0 && !seen[w[0]] {
+			seen[w[0]] = true
+			names = append(names, w[0])
+		}
+	}
+	return names
+}
+
+type usageError struct {
+	message string
+	usage   string
+}
+
+func (e *usageError) Error() string {
+	if e.usage == "" {
+		return e.message
+	}
+	return e.message + "\nUsage: " + e.usage
+}
+
+// parse finds the command and reads its arguments.
+func (cs commandSet) parse(keyword string, c slack.CommandInfo) (*command, args, error) {
+	raw := commandText(c)
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return nil, args{}, &usageError{message: "I couldn't read that, " + err.Error()}
+	}
+	if len(tokens) > 0 {
+		tokens = tokens[1:]
+	}
+	cmd, rest := cs.find(tokens)
+	if cmd != nil && len(rest) > 0 && len(cmd.args) == 0 && (cmd.name == "" || cs.hasUnder(cmd.name)) {
+		cmd = nil
+	}
+	if cmd == nil {
+		return nil, args{}, &usageError{message: cs.unknown(keyword, tokens)}
+	}
+	a, err := cmd.parseArgs([]rune(raw), rest)
+	if err != nil {
+		return cmd, a, &usageError{message: err.Error(), usage: cmd.usage(keyword)}
+	}
+	return cmd, a, nil
+}
+
+// unknown tells what is wrong with a command that matches none of the set,
+// and what could come next instead.
+func (cs commandSet) unknown(keyword string, tokens []token) string {
+	var group []string
+	for _, t := range tokens {
+		next := strings.ToLower(strings.Join(append(group, t.text), " "))
+		if t.quoted || !cs.hasUnder(next) {
+			break
+		}
+		group = strings.Fields(next)
+	}
+	under := strings.TrimSpace(keyword + " " + strings.Join(group, " "))
+	names := "`" + under + "` has " + strings.Join(cs.names(strings.Join(group, " ")), ", ")
+	if len(tokens) == len(group) {
+		return "Which one? " + names
+	}
+	typed := []string{keyword}
+	for _, t := range tokens[:len(group)+1] {
+		typed = append(typed, t.text)
+	}
+	return "I don't know `" + strings.Join(typed, " ") + "`, " + names
+}
+
+// parseArgs reads the arguments in order. A text argument takes the rest of
+// the command as it was typed, quotes and all, unless it is one quoted
+// string or has flags in it.
+func (cmd *command) parseArgs(raw []rune, tokens []token) (a args, err error) {
+	a = args{values: make(map[string]any), flags: make(map[string]bool)}
+	var rest []token
+	for _, t := range tokens {
+		isFlag := false
+		for _, f := range cmd.flags {
+			if !t.quoted && strings.ToLower(t.text) == f {
+				a.flags[f] = true
+				isFlag = true
+			}
+		}
+		if !isFlag {
+			rest = append(rest, t)
+		}
+	}
+	hasFlags := len(rest) < len(tokens)
+
+	for _, spec := range cmd.args {
+		if len(rest) == 0 {
+			if !spec.optional {
+				return a, errors.New("`" + spec.name + "` is missing")
+			}
+			continue
+		}
+		if spec.kind == textArg {
+			if len(rest) == 1 || hasFlags {
+				var words []string
+				for _, t := range rest {
+					words = append(words, t.text)
+				}
+				a.values[spec.name] = strings.Join(words, " ")
+			} else {
+				a.values[spec.name] = string(raw[rest[0].start:rest[len(rest)-1].end])
+			}
+			rest = nil
+			continue
+		}
+		t := rest[0]
+		value, err := spec.read(t.text)
+		if err != nil {
+			return a, err
+		}
+		a.values[spec.name] = value
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		var extra []string
+		for _, t := range rest {
+			extra = append(extra, t.text)
+		}
+		return a, errors.New("I don't know what to do with `" + strings.Join(extra, " ") + "`")
+	}
+	return a, nil
+}
+
+func (spec argSpec) read(s string) (any, error) {
+	switch spec.kind {
+	case numberArg:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("`" + spec.name + "` should be a number, not " + s)
+		}
+		return n, nil
+	case durationArg:
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, errors.New("`" + spec.name + "` should be a duration like 30m or 1h30m, not " + s)
+		}
+		return d, nil
+	case mentionArg:
+		userID, ok := mentionedUser(s)
+		if !ok {
+			return nil, errors.New("`" + spec.name + "` should mention someone, not " + s)
+		}
+		return strings.SplitN(userID, "|", 2)[0], nil
+	}
+	return s, nil
+}
+
+// usage writes the command the way it is typed, like
+// `holiday add <days> [name...]`.
+func (cmd *command) usage(keyword string) string {
+	parts := []string{keyword}
+	if cmd.name != "" {
+		parts = append(parts, cmd.name)
+	}
+	for _, spec := range cmd.args {
+		name := spec.name
+		if spec.kind == textArg {
+			name += "..."
+		}
+		if spec.optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	for _, f := range cmd.flags {
+		parts = append(parts, "["+f+"]")
+	}
+	return "`" + strings.Join(parts, " ") + "`"
+}
+
+// runCommand parses a command from the set and runs it, or answers with
+// what went wrong and how the command is used.
+func (lj *labJob) runCommand(cs commandSet, c slack.CommandInfo) {
+	cmd, a, err := cs.parse(strings.ToLower(c.Fields[0]), c)
+	if err != nil {
+		go lj.logger.WithField("fields", c.Fields).WithError(err).Info("Cannot parse command")
+		lj.messenger.PostMessage(c.Channel, err.Error())
+		return
+	}
+	cmd.run(c, a)
+}
+
+// The help job lists the jobs, and the commands of those that declare them.
+
+type commandJob interface {
+	commands() commandSet
+}
+
+type helpJob struct {
+	labJob
+	jh *JobHandler
+}
+
+func newHelpJob(jh *JobHandler) *helpJob {
+	return &helpJob{
+		labJob: labJob{
+			name:      "Help",
+			keyword:   "help",
+			active:    true,
+			desc:      "Lists the jobs and how to use their commands",
+			logger:    jh.logger.WithFields(log.Fields{"jobtype": "bot", "job": "help"}),
+			messenger: jh.messenger,
+		},
+		jh: jh,
+	}
+}
+
+func (hj *helpJob) commands() commandSet {
+	return commandSet{
+		{args: []argSpec{word("job").opt(), text("subcommand").opt()},
+			desc: "Lists the jobs, the commands of one, or how to use one of them", run: hj.help},
+	}
+}
+
+func (hj *helpJob) commandProcessor(c slack.CommandInfo) {
+	if !hj.active {
+		hj.messenger.PostMessage(c.Channel, "The "+hj.name+" job is disabled")
+		return
+	}
+	hj.runCommand(hj.commands(), c)
+}
+
+func (hj *helpJob) help(c slack.CommandInfo, a args) {
+	if !a.has("job") {
+		hj.messenger.PostMessage(c.Channel, hj.jobList())
+		return
+	}
+	keyword := strings.ToLower(a.word("job"))
+	key, ok := hj.jh.find(keyword)
+	if !ok {
+		hj.messenger.PostMessage(c.Channel, "There is no job called "+keyword+", `help` lists them")
+		return
+	}
+	j := hj.jh.jobs[key]
+	name, desc := describeJob(j)
+	lines := []string{"*" + name + "*: " + desc}
+	cj, ok := j.(commandJob)
+	if !ok {
+		hj.messenger.PostMessage(c.Channel, lines[0])
+		return
+	}
+	prefix := strings.ToLower(strings.Join(strings.Fields(a.word("subcommand")), " "))
+	shown := 0
+	for _, cmd := range cj.commands() {
+		if prefix != "" && cmd.name != prefix && !strings.HasPrefix(cmd.name, prefix+" ") {
+			continue
+		}
+		lines = append(lines, cmd.usage(keyword)+" : "+cmd.desc)
+		shown++
+	}
+	if shown == 0 {
+		hj.messenger.PostMessage(c.Channel, "`"+keyword+"` has no command "+prefix+", `help "+keyword+"` lists them")
+		return
+	}
+	hj.messenger.PostMessage(c.Channel, strings.Join(lines, "\n"))
+}
+
+func (hj *helpJob) jobList() string {
+	var keys []string
+	for key := range hj.jh.jobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := []string{"*Jobs*, `help <job>` shows what they do"}
+	for _, key := range keys {
+		_, desc := describeJob(hj.jh.jobs[key])
+		lines = append(lines, "`"+key+"` : "+desc)
+	}
+	return strings.Join(lines, "\n")
+}
+
+type describedJob interface {
+	describe() (name string, desc string)
+}
+
+func (lj *labJob) describe() (name string, desc string) {
+	return lj.name, lj.desc
+}
+
+func describeJob(j job) (name string, desc string) {
+	if dj, ok := j.(describedJob); ok {
+		return dj.describe()
+	}
+	return "", ""
+}
@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// A group is a controller without a device of its own. Its power commands
+// switch each of its member controllers and report on all of them at once,
+// and everything else, like schedules and history, works as for any other
+// controller.
+
+type memberResult struct {
+	name    string
+	state   string
+	changed bool
+	err     error
+}
+
+// switchPower turns the device on or off for a group or a scene, which
+// report on all their devices at once instead of each device posting.
+func (cj *controllerJob) switchPower(c slack.CommandInfo, powerState string, force bool) (r memberResult) {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	r = memberResult{name: cj.machineName, state: powerState}
+	switch {
+	case !cj.active:
+		r.err = errors.New("the " + cj.name + " is disabled")
+	case cj.powerState == powerState && !force:
+	default:
+		r.err = cj.power(c, powerState, commandSource(c, force), force && isAdmin(c.User))
+		r.changed = r.err == nil
+	}
+	return r
+}
+
+func (r memberResult) String() string {
+	text := strings.ToUpper(r.name[:1]) + r.name[1:] + ": "
+	switch {
+	case r.err != nil:
+		return text + "couldn't turn " + r.state + ", _" + r.err.Error() + "_"
+	case r.changed:
+		return text + "turned " + r.state
+	}
+	return text + "already " + r.state
+}
+
+// describeResults lists the result for each device and counts the failures.
+func describeResults(results []memberResult) (lines string, failed int) {
+	for _, r := range results {
+		lines += "\n" + r.String()
+		if r.err != nil {
+			failed++
+		}
+	}
+	return lines, failed
+}
+
+func devicesFailed(failed int, total int) string {
+	return fmt.Sprintf("%d of %d devices failed", failed, total)
+}
+
+// switches gives the power commands a group command runs on its members,
+// which are also what a schedule or one-shot of the group runs.
+func (cj *controllerJob) switches(c slack.CommandInfo) (commands []slack.CommandInfo) {
+	if cj.members == nil {
+		return nil
+	}
+	cmd, _, _ := cj.commands().parse(strings.ToLower(c.Fields[0]), c)
+	if cmd == nil {
+		return nil
+	}
+	power := cmd.schedules
+	switch cmd.name {
+	case "on", "off", "force on", "force off":
+		power = cmd.name
+	}
+	if power == "" {
+		return nil
+	}
+	for _, member := range cj.members {
+		commands = append(commands, switchCommand(member, c, power))
+	}
+	return commands
+}
+
+func (cj *controllerJob) groupPowerControl(c slack.CommandInfo, powerState string, force bool) {
+	var results []memberResult
+	changed := false
+	for _, member := range cj.members {
+		r := member.switchPower(c, powerState, force)
+		changed = changed || r.changed || r.err != nil
+		results = append(results, r)
+	}
+	if !changed {
+		message := "The " + cj.machineName + " is already " + powerState
+		go cj.logger.Info(message)
+		cj.messenger.PostMessage(c.Channel, message)
+		return
+	}
+
+	lines, failed := describeResults(results)
+	var err error
+	if failed > 0 {
+		err = errors.New(devicesFailed(failed, len(results)))
+	}
+	cj.recordPowerEvent(powerState, commandSource(c, force), c.User, err)
+
+	var message string
+	if err == nil {
+		cj.setPowerState(powerState, true)
+		message = "Turned " + powerState + " the " + cj.machineName
+		go cj.logger.Info(message)
+		if c.TimeStamp != "" {
+			cj.messenger.React(c.TimeStamp, c.Channel, "ok_hand")
+		}
+	} else {
+		message = "Couldn't turn " + powerState + " all of the " + cj.machineName + ", " + err.Error()
+		go cj.logger.WithError(err).Error(message)
+	}
+	cj.messenger.Message(message + lines)
+}
+
+// groupStatus shows the state of each device in the group.
+func (cj *controllerJob) groupStatus(c slack.CommandInfo) {
+	var lines string
+	on := 0
+	for _, member := range cj.members {
+		member.mu.Lock()
+		state := member.powerState
+		member.mu.Unlock()
+		if state == "on" {
+			on++
+		}
+		name := strings.ToUpper(member.machineName[:1]) + member.machineName[1:]
+		lines += "\n" + name + ": *" + state + "*"
+	}
+
+	message := "The " + cj.machineName + " is "
+	switch on {
+	case 0:
+		message += "*off*"
+	case len(cj.members):
+		message += "*on*"
+	default:
+		message += fmt.Sprintf("*partly on* (%d of %d devices)", on, len(cj.members))
+	}
+	cj.messenger.PostMessage(c.Channel, message+lines+"\n"+cj.schedulingStatus(false))
+}
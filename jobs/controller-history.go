@@ -0,0 +1,301 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/functions"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// Every power change of a controller is kept in its history bucket, in the
+// order it happened, so usage can be reported later.
+
+const (
+	sourceUser      = "user"
+	sourceSchedule  = "schedule"
+	sourceForce     = "force"
+	sourceDrift     = "drift"
+	sourceAutoOff   = "auto-off"
+	sourceInterlock = "interlock"
+
+	defaultHistoryLen = 10
+	maxHistoryLen     = 50
+	topUsers          = 3
+)
+
+var reportPeriods = map[string]time.Duration{
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+type powerEvent struct {
+	At     time.Time
+	State  string
+	Source string
+	User   string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+func (cj *controllerJob) historyPath() []string {
+	return append(append([]string{}, cj.dbPath...), "history")
+}
+
+func commandSource(c slack.CommandInfo, force bool) string {
+	switch {
+	case force:
+		return sourceForce
+	case c.Scheduled:
+		return sourceSchedule
+	}
+	return sourceUser
+}
+
+func (cj *controllerJob) recordPowerEvent(state string, source string, user string, err error) {
+	event := powerEvent{
+		At:     functions.Now(),
+		State:  state,
+		Source: source,
+		User:   user,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	buf, e := json.Marshal(event)
+	if e == nil {
+		var seq int
+		seq, e = db.IncrementBucketInteger(cj.historyPath())
+		if e == nil {
+			e = db.AddValue(cj.historyPath(), fmt.Sprintf("%016d", seq), buf)
+		}
+	}
+	if e != nil {
+		cj.logger.WithError(e).Error("Cannot add power event to the history")
+	}
+}
+
+func (cj *controllerJob) readHistory() (events []powerEvent, err error) {
+	err = db.RunCallbackOnEachKey(cj.historyPath(), func(key []byte, value []byte) error {
+		var event powerEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+func (cj *controllerJob) sendHistory(c slack.CommandInfo, a args) {
+	n := defaultHistoryLen
+	if a.has("n") {
+		n = a.number("n")
+		if n < 1 {
+			cj.errorMsg(c.Fields, c.Channel, "How many events? Like `"+cj.keyword+" history 20`")
+			return
+		}
+		if n > maxHistoryLen {
+			n = maxHistoryLen
+		}
+	}
+
+	events, err := cj.readHistory()
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, "Couldn't read the history of the "+cj.machineName)
+		return
+	}
+	if len(events) == 0 {
+		cj.messenger.PostMessage(c.Channel, "The "+cj.machineName+" hasn't been turned on or off yet")
+		return
+	}
+
+	var lines []string
+	reachable := true
+	for _, event := range events {
+		lines = append(lines, "`"+event.At.Format("Mon Jan 2 3:04 PM")+"` "+event.describe(reachable))
+		if event.Source == sourceDrift {
+			reachable = event.State != "unknown"
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	cj.messenger.PostMessage(c.Channel, "*Power history of the "+cj.machineName+"*\n"+strings.Join(lines, "\n"))
+}
+
+// describe tells what happened, given whether the device could be reached
+// before the event.
+func (e powerEvent) describe(reachable bool) string {
+	var who string
+	switch e.Source {
+	case sourceUser:
+		who = " by <@" + e.User + ">"
+	case sourceForce:
+		who = " by <@" + e.User + "> (forced)"
+	case sourceSchedule:
+		who = " by the schedule"
+	case sourceAutoOff:
+		who = " automatically"
+	case sourceInterlock:
+		who = " by an interlock"
+	case sourceDrift:
+		who = " outside the bot"
+	}
+
+	switch {
+	case e.Source == sourceDrift && e.State == "unknown":
+		return "couldn't be reached: _" + e.Error + "_"
+	case e.Source == sourceDrift && !reachable:
+		return "was reached again, it is " + e.State
+	case e.Error != "":
+		return "couldn't be turned " + e.State + who + ": _" + e.Error + "_"
+	}
+	return "turned " + e.State + who
+}
+
+func (cj *controllerJob) sendReport(c slack.CommandInfo, a args) {
+	period := "week"
+	if a.has("period") {
+		period = strings.ToLower(a.word("period"))
+	}
+	length, ok := reportPeriods[period]
+	if !ok {
+		cj.errorMsg(c.Fields, c.Channel, "Report for a week or a month? Like `"+cj.keyword+" report month`")
+		return
+	}
+
+	events, err := cj.readHistory()
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, "Couldn't read the history of the "+cj.machineName)
+		return
+	}
+	to := functions.Now()
+	report := summarizeHistory(events, to.Add(-length), to)
+	cj.messenger.PostMessage(c.Channel, "*Usage of the "+cj.machineName+" in the last "+period+"*\n"+report.String())
+}
+
+type usageReport struct {
+	length   time.Duration
+	onTime   time.Duration
+	cycles   int
+	onBy     map[string]int
+	offBy    map[string]int
+	users    map[string]int
+	failures int
+}
+
+// summarizeHistory adds up the time the device was on in (from, to] and who
+// switched it. The state at from is the last one recorded before it, only
+// changes of state count as switching it, and unreachable spells keep the
+// last known state like the controller does.
+func summarizeHistory(events []powerEvent, from time.Time, to time.Time) (r usageReport) {
+	r = usageReport{
+		length: to.Sub(from),
+		onBy:   make(map[string]int),
+		offBy:  make(map[string]int),
+		users:  make(map[string]int),
+	}
+	state := "off"
+	since := from
+	for _, e := range events {
+		if e.At.After(to) {
+			break
+		}
+		inPeriod := e.At.After(from)
+		if e.Error != "" || e.State == "unknown" {
+			if inPeriod && e.Source != sourceDrift {
+				r.failures++
+			}
+			continue
+		}
+		if !inPeriod {
+			state = e.State
+			continue
+		}
+
+		if e.User != "" {
+			r.users[e.User]++
+		}
+		if e.State == state {
+			continue
+		}
+		if state == "on" {
+			r.onTime += e.At.Sub(since)
+			r.offBy[e.Source]++
+		} else {
+			r.cycles++
+			r.onBy[e.Source]++
+			since = e.At
+		}
+		state = e.State
+	}
+	if state == "on" {
+		r.onTime += to.Sub(since)
+	}
+	return r
+}
+
+func (r usageReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "On for %s (%.0f%% of the time)", r.onTime.Round(time.Minute), 100*r.onTime.Seconds()/r.length.Seconds())
+	b.WriteString(", turned on " + timesString(r.cycles))
+	if line := countsByWho(r.onBy); line != "" {
+		b.WriteString("\nTurned on " + line)
+	}
+	if line := countsByWho(r.offBy); line != "" {
+		b.WriteString("\nTurned off " + line)
+	}
+
+	if len(r.users) > 0 {
+		users := functions.GetKeys(r.users)
+		sort.Slice(users, func(i, j int) bool {
+			if r.users[users[i]] != r.users[users[j]] {
+				return r.users[users[i]] > r.users[users[j]]
+			}
+			return users[i] < users[j]
+		})
+		if len(users) > topUsers {
+			users = users[:topUsers]
+		}
+		var top []string
+		for _, u := range users {
+			top = append(top, "<@"+u+"> ("+strconv.Itoa(r.users[u])+")")
+		}
+		b.WriteString("\nTop users: " + strings.Join(top, ", "))
+	}
+	if r.failures > 0 {
+		b.WriteString("\nFailed commands: " + strconv.Itoa(r.failures))
+	}
+	return b.String()
+}
+
+// countsByWho lists counts by who switched the device, people first.
+func countsByWho(counts map[string]int) string {
+	var parts []string
+	if n := counts[sourceUser] + counts[sourceForce]; n > 0 {
+		parts = append(parts, "by people "+timesString(n))
+	}
+	for _, s := range []struct{ source, label string }{
+		{sourceSchedule, "by the schedule "},
+		{sourceAutoOff, "automatically "},
+		{sourceInterlock, "by interlocks "},
+		{sourceDrift, "outside the bot "},
+	} {
+		if counts[s.source] > 0 {
+			parts = append(parts, s.label+timesString(counts[s.source]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func timesString(n int) string {
+	if n == 1 {
+		return "once"
+	}
+	return strconv.Itoa(n) + " times"
+}
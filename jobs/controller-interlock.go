@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/vishhvaan/lab-bot/config"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// Interlocks keep devices from running in unsafe combinations. A controller
+// can require others to be on, like a vacuum pump and the fume hood fan, and
+// can conflict with others that mustn't run at the same time, like two
+// heaters on one circuit. They are checked before the driver is called, and
+// the states they go by are kept here so no controller has to lock another
+// to read its state.
+
+type interlocks struct {
+	mu     sync.Mutex
+	states map[*controllerJob]string
+	rules  []interlockRule
+}
+
+type interlockRule struct {
+	controller *controllerJob
+	requires   []*controllerJob
+	conflicts  []*controllerJob
+	cascade    bool
+}
+
+type interlockError struct {
+	machine    string
+	powerState string
+	reasons    []string
+	forced     bool
+}
+
+func (e *interlockError) Error() string {
+	return strings.Join(e.reasons, ", ")
+}
+
+func (e *interlockError) message() string {
+	message := "The " + e.machine + " can't be turned " + e.powerState + ": " + e.Error()
+	if e.forced {
+		message += "\n_Only admins can force past an interlock._"
+	}
+	return message
+}
+
+func (il *interlocks) setState(cj *controllerJob, state string) {
+	if il == nil {
+		return
+	}
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	il.states[cj] = state
+}
+
+// check tells whether the controller may be turned to powerState, and which
+// controllers have to be turned off first.
+func (il *interlocks) check(cj *controllerJob, powerState string) (cascade []*controllerJob, err error) {
+	if il == nil {
+		return nil, nil
+	}
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	var reasons []string
+	for _, rule := range il.rules {
+		switch {
+		case powerState == "on" && rule.controller == cj:
+			for _, r := range rule.requires {
+				if il.states[r] != "on" {
+					reasons = append(reasons, "it needs the "+r.machineName+" on")
+				}
+			}
+			for _, c := range rule.conflicts {
+				if il.states[c] != "off" {
+					reasons = append(reasons, "it can't run while the "+c.machineName+il.stateText(c))
+				}
+			}
+		case powerState == "on" && containsController(rule.conflicts, cj) && il.states[rule.controller] != "off":
+			reasons = append(reasons, "it can't run while the "+rule.controller.machineName+il.stateText(rule.controller))
+		case powerState == "off" && containsController(rule.requires, cj) && il.states[rule.controller] != "off":
+			if rule.cascade {
+				cascade = append(cascade, rule.controller)
+			} else {
+				reasons = append(reasons, "the "+rule.controller.machineName+" needs it, turn that off first")
+			}
+		}
+	}
+	if len(reasons) > 0 {
+		return cascade, &interlockError{machine: cj.machineName, powerState: powerState, reasons: reasons}
+	}
+	return cascade, nil
+}
+
+// cascadeOff turns off the controllers that need this one before it goes
+// off, and stops at the first one that fails.
+func (cj *controllerJob) cascadeOff(cascade []*controllerJob) error {
+	for _, dependent := range cascade {
+		dependent.mu.Lock()
+		on := dependent.powerState != "off"
+		var err error
+		if on {
+			err = dependent.power(slack.CommandInfo{
+				Fields: []string{dependent.keyword, "off"},
+			}, "off", sourceInterlock, false)
+		}
+		dependent.mu.Unlock()
+		if err != nil {
+			return errors.New("couldn't turn off the " + dependent.machineName + " first, " + err.Error())
+		}
+		if on {
+			message := "Turned off the " + dependent.machineName + ", it needs the " + cj.machineName
+			go dependent.logger.Info(message)
+			cj.messenger.Message(message)
+		}
+	}
+	return nil
+}
+
+// interlockDrift follows up on the device being turned off outside the
+// bot: the controllers that need it are turned off if they cascade, and
+// otherwise the lab is told they are running without it.
+func (cj *controllerJob) interlockDrift() {
+	cascade, err := cj.interlocks.check(cj, "off")
+	if e := cj.cascadeOff(cascade); e != nil {
+		go cj.logger.WithError(e).Error("Cannot cascade a power off")
+		cj.messenger.Message("The " + cj.machineName + " is off, but I " + e.Error())
+	}
+	if err != nil {
+		go cj.logger.WithError(err).Warn("Interlock broken outside the bot")
+		cj.messenger.Message("The " + cj.machineName + " is off, but " + err.Error())
+	}
+}
+
+func (il *interlocks) stateText(cj *controllerJob) string {
+	if il.states[cj] == "unknown" {
+		return " can't be reached"
+	}
+	return " is " + il.states[cj]
+}
+
+func containsController(controllers []*controllerJob, cj *controllerJob) bool {
+	for _, c := range controllers {
+		if c == cj {
+			return true
+		}
+	}
+	return false
+}
+
+// buildInterlocks resolves the interlock rules of the config and hands them
+// to the controllers they involve.
+func (jh *JobHandler) buildInterlocks(ics []config.InterlockConfig, configs []config.ControllerConfig) error {
+	if len(ics) == 0 {
+		return nil
+	}
+	il := &interlocks{states: make(map[*controllerJob]string)}
+	for _, ic := range ics {
+		cj, err := jh.controller(ic.Controller, configs)
+		if err != nil {
+			return errors.New("interlock of " + ic.Controller + ": " + err.Error())
+		}
+		if cj == nil {
+			continue
+		}
+		rule := interlockRule{controller: cj, cascade: ic.Cascade}
+		for _, keywords := range []struct {
+			list *[]*controllerJob
+			from []string
+		}{{&rule.requires, ic.Requires}, {&rule.conflicts, ic.Conflicts}} {
+			for _, keyword := range keywords.from {
+				other, err := jh.controller(keyword, configs)
+				switch {
+				case err != nil:
+					return errors.New("interlock of " + ic.Controller + ": " + err.Error())
+				case other == nil:
+					return errors.New("interlock of " + ic.Controller + ": controller \"" + keyword + "\" is disabled")
+				case other == cj:
+					return errors.New("interlock of " + ic.Controller + ": a controller can't depend on itself")
+				}
+				*keywords.list = append(*keywords.list, other)
+			}
+		}
+		if len(rule.requires) == 0 && len(rule.conflicts) == 0 {
+			return errors.New("interlock of " + ic.Controller + " needs requires or conflicts")
+		}
+		il.rules = append(il.rules, rule)
+	}
+	if err := il.checkCycles(); err != nil {
+		return err
+	}
+
+	for _, j := range jh.jobs {
+		if cj, ok := j.(*controllerJob); ok && cj.members == nil {
+			cj.interlocks = il
+			il.states[cj] = cj.powerState
+		}
+	}
+	return nil
+}
+
+// checkCycles refuses controllers that end up requiring themselves, which
+// could never be turned on.
+func (il *interlocks) checkCycles() error {
+	requires := make(map[*controllerJob][]*controllerJob)
+	for _, rule := range il.rules {
+		requires[rule.controller] = append(requires[rule.controller], rule.requires...)
+	}
+	var visit func(cj *controllerJob, path []*controllerJob) error
+	visit = func(cj *controllerJob, path []*controllerJob) error {
+		if containsController(path, cj) {
+			return errors.New("interlock of " + cj.keyword + ": it ends up requiring itself")
+		}
+		for _, r := range requires[cj] {
+			if err := visit(r, append(path, cj)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for cj := range requires {
+		if err := visit(cj, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
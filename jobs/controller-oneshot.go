@@ -0,0 +1,235 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/functions"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// One-shot commands like "coffee on in 20m" or "bath off at 17:30" run once
+// and remove themselves. They are kept next to the schedule records, so they
+// survive restarts; one missed by more than oneShotGrace while the bot was
+// down is dropped rather than run late.
+
+const oneShotGrace = 15 * time.Minute
+
+type oneShot struct {
+	ID      string
+	At      time.Time
+	Power   string
+	Channel string
+	User    string
+	timer   *time.Timer
+}
+
+func (cj *controllerJob) oneShotPath() []string {
+	return append(append([]string{}, cj.scheduling.DbPath...), "oneshots")
+}
+
+// parseWhen reads "in <duration>", "at <time>" and "tomorrow [at] <time>".
+// A time that has passed today means tomorrow.
+func parseWhen(fields []string, now time.Time) (at time.Time, err error) {
+	if len(fields) < 2 {
+		return at, errors.New("when? Like `in 20m`, `at 17:30` or `tomorrow 7am`")
+	}
+	switch strings.ToLower(fields[0]) {
+	case "in":
+		d, err := time.ParseDuration(strings.Join(fields[1:], ""))
+		if err != nil || d <= 0 {
+			return at, errors.New("I couldn't read " + strings.Join(fields[1:], " ") + " as a duration, try something like 20m or 1h30m")
+		}
+		return now.Add(d), nil
+	case "at":
+		at, err = parseClock(fields[1:], now)
+		if err == nil && !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, err
+	case "tomorrow":
+		rest := fields[1:]
+		if strings.ToLower(rest[0]) == "at" {
+			rest = rest[1:]
+		}
+		at, err = parseClock(rest, now)
+		return at.AddDate(0, 0, 1), err
+	}
+	return at, errors.New("when? Like `in 20m`, `at 17:30` or `tomorrow 7am`")
+}
+
+func parseClock(fields []string, now time.Time) (at time.Time, err error) {
+	hour, minute, err := functions.ParseTimeOfDay(strings.Join(fields, ""))
+	if err != nil {
+		return at, errors.New("I couldn't read " + strings.Join(fields, " ") + " as a time, try something like 17:30 or 7am")
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location()), nil
+}
+
+func (cj *controllerJob) scheduleOneShot(c slack.CommandInfo, powerState string, when []string) {
+	at, err := parseWhen(when, functions.Now())
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+
+	idNum, err := db.IncrementBucketInteger(cj.scheduling.DbPath)
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, "couldn't get ID for schedule")
+		return
+	}
+	o := &oneShot{
+		ID:      functions.SHA256Sum(strconv.Itoa(idNum)+c.Fields[0]+"controller", controllerIDLen),
+		At:      at,
+		Power:   powerState,
+		Channel: c.Channel,
+		User:    c.User,
+	}
+	buf, err := json.Marshal(o)
+	if err == nil {
+		err = db.AddValue(cj.oneShotPath(), o.ID, buf)
+	}
+	cj.audit(c, "schedule", "add "+o.ID+" power "+powerState+" at "+at.Format("2006-01-02 15:04"), err)
+	if err != nil {
+		cj.logger.WithError(err).Error("Cannot add one-shot command to db")
+		cj.errorMsg(c.Fields, c.Channel, "Couldn't save the command, try again")
+		return
+	}
+	cj.oneShots[o.ID] = o
+	cj.armOneShot(o)
+
+	cj.sendMsg(c.Channel, "_The "+cj.machineName+" turns "+powerState+" at "+formatClock(at)+
+		"._ `@lab-bot "+cj.keyword+" schedule remove "+o.ID+"` cancels it.")
+}
+
+func (cj *controllerJob) loadOneShots() {
+	err := db.RunCallbackOnEachKey(cj.oneShotPath(), func(key []byte, value []byte) error {
+		o := &oneShot{}
+		if err := json.Unmarshal(value, o); err != nil {
+			return err
+		}
+		cj.oneShots[o.ID] = o
+		return nil
+	})
+	if err != nil {
+		cj.logger.WithError(err).Error("Cannot load one-shot commands from db")
+	}
+	for _, o := range cj.oneShots {
+		cj.armOneShot(o)
+	}
+}
+
+// armOneShot sets a timer for the command, or runs it right away if it is
+// due.
+func (cj *controllerJob) armOneShot(o *oneShot) {
+	wait := o.At.Sub(functions.Now())
+	if wait <= 0 {
+		cj.fireOneShot(o.ID)
+		return
+	}
+	o.timer = functions.AfterFunc(wait, func() {
+		cj.mu.Lock()
+		defer cj.mu.Unlock()
+		cj.fireOneShot(o.ID)
+	})
+}
+
+func (cj *controllerJob) fireOneShot(id string) {
+	o, ok := cj.oneShots[id]
+	if !ok {
+		return
+	}
+	cj.removeOneShot(id)
+
+	if late := functions.Now().Sub(o.At); late > oneShotGrace {
+		message := "_Didn't turn " + o.Power + " the " + cj.machineName + " at " + formatClock(o.At) +
+			", the bot wasn't running then._"
+		go cj.logger.Warn(message)
+		cj.messenger.Message(message)
+		return
+	}
+	cj.powerControl(slack.CommandInfo{
+		Fields:    []string{cj.keyword, o.Power},
+		Channel:   o.Channel,
+		Scheduled: true,
+	}, o.Power, false)
+}
+
+func (cj *controllerJob) removeOneShot(id string) bool {
+	o, ok := cj.oneShots[id]
+	if !ok {
+		return false
+	}
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+	delete(cj.oneShots, id)
+	if err := db.DeleteValue(cj.oneShotPath(), id); err != nil {
+		cj.logger.WithError(err).WithField("id", id).Error("Cannot delete one-shot command from db")
+	}
+	return true
+}
+
+func (cj *controllerJob) oneShotDueEvents(from time.Time, to time.Time) (events []dueEvent) {
+	for _, o := range cj.oneShots {
+		if o.At.After(from) && !o.At.After(to) {
+			id := o.ID
+			events = append(events, dueEvent{at: o.At, run: func() {
+				cj.mu.Lock()
+				defer cj.mu.Unlock()
+				cj.fireOneShot(id)
+			}})
+		}
+	}
+	return events
+}
+
+// oneShotStatus lists the pending one-shot commands, soonest first.
+func (cj *controllerJob) oneShotStatus(withIDs bool) string {
+	pending := make([]*oneShot, 0, len(cj.oneShots))
+	for _, o := range cj.oneShots {
+		pending = append(pending, o)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].At.Before(pending[j].At)
+	})
+
+	var b strings.Builder
+	for _, o := range pending {
+		if withIDs {
+			b.WriteString("`" + o.ID + "` ")
+		}
+		b.WriteString(fmt.Sprintf("*Once %s*: At %s\n", strings.Title(o.Power), formatClock(o.At)))
+	}
+	return b.String()
+}
+
+// schedulingStatus adds the pending one-shot commands and the runs skipped
+// for holidays lately to the schedules.
+func (cj *controllerJob) schedulingStatus(withIDs bool) string {
+	status := cj.scheduling.ContGetSchedulingStatus()
+	if withIDs {
+		status = cj.scheduling.ContList()
+	}
+	if len(cj.oneShots) != 0 {
+		if !cj.scheduling.Set {
+			status = ""
+		}
+		status += cj.oneShotStatus(withIDs)
+	}
+
+	skipped := cj.holidays.Skipped(cj.keyword, functions.Now().Add(-skippedRunsAge))
+	if len(skipped) > skippedRunsShown {
+		skipped = skipped[len(skipped)-skippedRunsShown:]
+	}
+	for _, run := range skipped {
+		status = strings.TrimSuffix(status, "\n") + "\nSkipped " + describeSkippedRun(run) + "\n"
+	}
+	return status
+}
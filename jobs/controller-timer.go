@@ -0,0 +1,188 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/functions"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// Controllers with a max_on duration turn their device off once it has been
+// on that long, whoever turned it on. A warning goes out first, and the
+// deadline can be pushed back with extend, though never more than max_on
+// past the current time.
+
+const (
+	defaultMaxOnWarning = 10 * time.Minute
+	autoOffRetry        = time.Minute
+)
+
+type autoOffTimer struct {
+	maxOn   time.Duration
+	warning time.Duration
+	at      time.Time
+	warned  bool
+	timer   *time.Timer
+}
+
+// startAutoOff sets the deadline when the device turns on and clears it
+// when it turns off.
+func (cj *controllerJob) startAutoOff() {
+	switch {
+	case cj.powerState == "on" && cj.autoOff.maxOn > 0 && cj.autoOff.at.IsZero():
+		cj.autoOff.at = cj.lastPowerOn.Add(cj.autoOff.maxOn)
+		cj.autoOff.warned = false
+	case cj.powerState == "off" || cj.autoOff.maxOn == 0:
+		cj.autoOff.at = time.Time{}
+	}
+	cj.armAutoOff()
+}
+
+// armAutoOff sets a timer for the next warning or deadline, or checks right
+// away if that has already passed.
+func (cj *controllerJob) armAutoOff() {
+	if cj.autoOff.timer != nil {
+		cj.autoOff.timer.Stop()
+		cj.autoOff.timer = nil
+	}
+	if cj.autoOff.at.IsZero() {
+		return
+	}
+	next := cj.autoOff.at
+	if !cj.autoOff.warned {
+		next = cj.autoOff.at.Add(-cj.autoOff.warning)
+	}
+	if !next.After(functions.Now()) {
+		cj.checkAutoOff()
+		return
+	}
+	cj.autoOff.timer = functions.AfterFunc(next.Sub(functions.Now()), cj.runAutoOff)
+}
+
+func (cj *controllerJob) runAutoOff() {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	cj.checkAutoOff()
+}
+
+func (cj *controllerJob) checkAutoOff() {
+	if cj.autoOff.at.IsZero() {
+		return
+	}
+	now := functions.Now()
+
+	if now.Before(cj.autoOff.at) {
+		if !cj.autoOff.warned && !now.Before(cj.autoOff.at.Add(-cj.autoOff.warning)) {
+			cj.autoOff.warned = true
+			message := "The " + cj.machineName + " turns off automatically in " +
+				fmt.Sprint(cj.autoOff.at.Sub(now).Round(time.Second)) +
+				". `@lab-bot " + cj.keyword + " extend 30m` keeps it on longer."
+			go cj.logger.Info(message)
+			cj.messenger.Message(message)
+		}
+		cj.armAutoOff()
+		return
+	}
+
+	err := cj.switchDevice(slack.CommandInfo{Fields: []string{cj.keyword, "off"}}, "off", sourceAutoOff, false)
+	var ie *interlockError
+	if errors.As(err, &ie) {
+		message := "Didn't turn off the " + cj.machineName + " after " + fmt.Sprint(cj.autoOff.maxOn) + ", " + err.Error()
+		go cj.logger.WithError(err).Warn(message)
+		cj.messenger.Message(message)
+		cj.autoOff.at = time.Time{}
+		cj.updatePowerStateInDB()
+		return
+	}
+	if err != nil {
+		message := "Couldn't turn off the " + cj.machineName + " after " + fmt.Sprint(cj.autoOff.maxOn) +
+			", trying again in " + fmt.Sprint(autoOffRetry) + "\n_" + err.Error() + "_"
+		go cj.logger.WithError(err).Error(message)
+		cj.messenger.Message(message)
+		cj.autoOff.at = now.Add(autoOffRetry)
+		cj.autoOff.warned = true
+		cj.updatePowerStateInDB()
+		cj.armAutoOff()
+		return
+	}
+
+	message := "Turned off the " + cj.machineName + ", it was on for " +
+		fmt.Sprint(now.Sub(cj.lastPowerOn).Round(time.Second))
+	go cj.logger.Info(message)
+	cj.messenger.Message(message)
+	cj.setPowerState("off", true)
+}
+
+func (cj *controllerJob) extendAutoOff(c slack.CommandInfo, a args) {
+	if cj.autoOff.maxOn == 0 {
+		cj.sendMsg(c.Channel, "The "+cj.machineName+" doesn't turn off automatically")
+		return
+	}
+	if cj.autoOff.at.IsZero() {
+		cj.sendMsg(c.Channel, "The "+cj.machineName+" is not on")
+		return
+	}
+	d := a.duration("time")
+
+	message := ""
+	at := cj.autoOff.at.Add(d)
+	if latest := functions.Now().Add(cj.autoOff.maxOn); at.After(latest) {
+		at = latest
+		message = "_It can't stay on for more than " + fmt.Sprint(cj.autoOff.maxOn) + " from now._\n"
+	}
+	cj.autoOff.at = at
+	cj.autoOff.warned = false
+	cj.updatePowerStateInDB()
+	cj.armAutoOff()
+
+	message += "The " + cj.machineName + " now turns off automatically at " + formatClock(at)
+	cj.sendMsg(c.Channel, message)
+}
+
+func (cj *controllerJob) autoOffStatus() string {
+	if cj.autoOff.at.IsZero() || cj.powerState != "on" {
+		return ""
+	}
+	return "\nTurns off automatically at " + formatClock(cj.autoOff.at)
+}
+
+func (cj *controllerJob) autoOffDueEvents(from time.Time, to time.Time) (events []dueEvent) {
+	if cj.autoOff.at.IsZero() {
+		return nil
+	}
+	for _, at := range []time.Time{cj.autoOff.at.Add(-cj.autoOff.warning), cj.autoOff.at} {
+		if at.After(from) && !at.After(to) {
+			events = append(events, dueEvent{at: at, run: cj.runAutoOff})
+		}
+	}
+	return events
+}
+
+func (cj *controllerJob) saveAutoOff() error {
+	buf, err := json.Marshal(cj.autoOff.at)
+	if err != nil {
+		return err
+	}
+	return db.AddValue(cj.dbPath, "autoOffAt", buf)
+}
+
+func (cj *controllerJob) loadAutoOff() error {
+	buf, err := db.ReadValue(cj.dbPath, "autoOffAt")
+	if err != nil || len(buf) == 0 {
+		return err
+	}
+	return json.Unmarshal(buf, &cj.autoOff.at)
+}
+
+// formatClock shows the time of day, with the day when it isn't today.
+func formatClock(t time.Time) string {
+	now := functions.Now()
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("3:04 PM")
+	}
+	return t.Format("Mon Jan 2 3:04 PM")
+}
@@ -2,44 +2,65 @@ package jobs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/drivers"
 	"github.com/vishhvaan/lab-bot/functions"
 	"github.com/vishhvaan/lab-bot/scheduling"
 	"github.com/vishhvaan/lab-bot/slack"
 )
 
-const controllerIDLen = 6
+const (
+	controllerIDLen     = 6
+	defaultPollInterval = time.Minute
+	maxPolls            = 10000
+)
 
 type controllerJob struct {
 	labJob
-	machineName string
-	powerState  string
-	lastPowerOn time.Time
-	device      any
-	customInit  func() (err error)
-	customOn    func() (err error)
-	customOff   func() (err error)
-	scheduling  scheduling.ControllerSchedule
-	dbPath      []string
+	machineName   string
+	powerState    string
+	lastPowerOn   time.Time
+	device        any
+	customInit    func() (err error)
+	customOn      func() (err error)
+	customOff     func() (err error)
+	customStatus  func() (state string, err error)
+	pollInterval  time.Duration
+	pollStart     time.Time
+	stopPolls     chan struct{}
+	listening     bool
+	knownState    string
+	autoOff       autoOffTimer
+	oneShots      map[string]*oneShot
+	members       []*controllerJob
+	interlocks    *interlocks
+	pendingScheds map[string]pendingSched
+	scheduling    scheduling.ControllerSchedule
+	dbPath        []string
+	mu            sync.Mutex
 	controller
 }
 
 type controller interface {
 	init()
-	TurnOn(c slack.CommandInfo)
-	TurnOff(c slack.CommandInfo)
-	getPowerStatus(c slack.CommandInfo)
+	TurnOn(c slack.CommandInfo, a args)
+	TurnOff(c slack.CommandInfo, a args)
+	getPowerStatus(c slack.CommandInfo, a args)
 	commandProcessor(c slack.CommandInfo)
 }
 
 func (cj *controllerJob) init() {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
 	cj.labJob.init()
 
 	cj.dbPath = append([]string{"jobs", "controller"}, cj.keyword)
@@ -53,8 +74,10 @@ func (cj *controllerJob) init() {
 		message = cj.name + " loaded"
 		cj.logger.Info(message)
 	}
-	slack.Message(message)
+	cj.messenger.Message(message)
 
+	cj.scheduling.Messenger = cj.messenger
+	cj.scheduling.Locker = &cj.mu
 	cj.scheduling.Sched = make(map[string]*scheduling.Schedule)
 	cj.scheduling.DbPath = append(cj.dbPath, "scheduling")
 	if cj.checkCreateBucket() {
@@ -63,32 +86,150 @@ func (cj *controllerJob) init() {
 	} else {
 		cj.updatePowerStateInDB()
 	}
+	cj.interlocks.setState(cj, cj.powerState)
+	if !db.CheckBucketExists(cj.historyPath()) {
+		db.CreateBucket(cj.historyPath())
+	}
+	cj.startAutoOff()
+	cj.oneShots = make(map[string]*oneShot)
+	if db.CheckBucketExists(cj.oneShotPath()) {
+		cj.loadOneShots()
+	} else {
+		db.CreateBucket(cj.oneShotPath())
+	}
+
+	if sn, ok := cj.device.(drivers.StateNotifier); ok && !cj.listening {
+		sn.OnStateChange(cj.deviceStateChanged)
+		cj.listening = true
+	}
+	if cj.customStatus != nil && cj.pollInterval > 0 {
+		cj.pollStart = functions.Now()
+		if !functions.Faked() {
+			cj.stopPolls = make(chan struct{})
+			go cj.pollDevice(cj.stopPolls)
+		}
+	}
+}
+
+// stop stops the poller, the schedules and the timers, which init starts
+// again.
+func (cj *controllerJob) stop() {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	if cj.stopPolls != nil {
+		close(cj.stopPolls)
+		cj.stopPolls = nil
+	}
+	cj.pollStart = time.Time{}
+	cj.scheduling.Stop()
+	if cj.autoOff.timer != nil {
+		cj.autoOff.timer.Stop()
+		cj.autoOff.timer = nil
+	}
+	for _, o := range cj.oneShots {
+		if o.timer != nil {
+			o.timer.Stop()
+		}
+	}
+}
+
+// enable, disable and isActive take the lock, which the poller and timers
+// hold while they check whether the controller is active.
+func (cj *controllerJob) enable() {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	cj.labJob.enable()
+}
+
+func (cj *controllerJob) disable() {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	cj.labJob.disable()
+}
+
+func (cj *controllerJob) isActive() bool {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	return cj.labJob.isActive()
+}
+
+func (cj *controllerJob) requiredRoles() map[string][]string {
+	return map[string][]string{
+		"force":           {adminRole, techRole},
+		"schedule remove": {adminRole, postdocRole, techRole},
+	}
+}
+
+func (cj *controllerJob) commands() commandSet {
+	return commandSet{
+		{desc: "Shows whether it is on", run: cj.getPowerStatus},
+		{name: "status", desc: "Shows whether it is on, for how long and its schedules", run: cj.getPowerStatus},
+		{name: "on", args: []argSpec{text("when").opt()},
+			desc: "Turns it on, or later when given a time like `in 20m`, `at 17:30` or `tomorrow 7am`", run: cj.TurnOn},
+		{name: "off", args: []argSpec{text("when").opt()},
+			desc: "Turns it off, or later when given a time like `in 20m`, `at 17:30` or `tomorrow 7am`", run: cj.TurnOff},
+		{name: "force on", desc: "Turns it on even if an interlock or its state says not to", run: cj.turnOnForce},
+		{name: "force off", desc: "Turns it off even if an interlock or its state says not to", run: cj.turnOffForce},
+		{name: "extend", args: []argSpec{duration("time")}, desc: "Keeps it on longer before it turns off by itself", run: cj.extendAutoOff},
+		{name: "history", args: []argSpec{number("n").opt()}, desc: "Shows the latest times it was switched", run: cj.sendHistory},
+		{name: "report", args: []argSpec{word("period").opt()}, desc: "Shows how long it was on over the last week or month", run: cj.sendReport},
+		{name: "schedule", desc: "Shows its schedules", run: cj.sendSchedulingStatus},
+		{name: "schedule status", desc: "Shows its schedules", run: cj.sendSchedulingStatus},
+		{name: "schedule list", desc: "Lists its schedules with their IDs", run: cj.listScheds},
+		{name: "schedule on set", args: []argSpec{text("schedule")}, schedules: "on",
+			desc: "Turns it on with cron or a phrase like `every weekday at 8am`, ending in `as <label>` to name it", run: cj.sched("on")},
+		{name: "schedule off set", args: []argSpec{text("schedule")}, schedules: "off",
+			desc: "Turns it off with cron or a phrase like `every weekday at 6pm`, ending in `as <label>` to name it", run: cj.sched("off")},
+		{name: "schedule on remove", desc: "Removes the schedule that turns it on, when there is one", run: cj.removeSchedByPower("on")},
+		{name: "schedule off remove", desc: "Removes the schedule that turns it off, when there is one", run: cj.removeSchedByPower("off")},
+		{name: "schedule confirm", desc: "Saves the schedule you just set with a phrase", run: cj.confirmPendingSched},
+		{name: "schedule remove", args: []argSpec{text("schedule")}, desc: "Removes a schedule by its ID or label", run: cj.removeSched},
+		{name: "schedule holidays", args: []argSpec{word("schedule"), word("mode")},
+			desc: "Sets whether a schedule should `respect` holidays and skip them, or `ignore` them", run: cj.schedHolidays},
+		{name: "schedule pause", args: []argSpec{text("schedule").opt()},
+			desc: "Pauses a schedule or all of them, ending in `until <day>` to resume then", run: cj.pauseScheds},
+		{name: "schedule resume", args: []argSpec{text("schedule").opt()}, desc: "Resumes a schedule or all of them", run: cj.resumeScheds},
+		{name: "schedule skip-next", args: []argSpec{text("schedule").opt()},
+			desc: "Skips the next run of a schedule, or the one coming up first", run: cj.skipNextSched},
+	}
 }
 
 func (cj *controllerJob) commandProcessor(c slack.CommandInfo) {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
 	if cj.active {
-		controllerActions := map[string]action{
-			"on":       cj.TurnOn,
-			"off":      cj.TurnOff,
-			"status":   cj.getPowerStatus,
-			"schedule": cj.scheduleHandler,
-			"force":    cj.forcePower,
+		cj.runCommand(cj.commands(), c)
+	} else {
+		cj.messenger.PostMessage(c.Channel, "The "+cj.name+" is disabled")
+	}
+}
+
+func (cj *controllerJob) dueEvents(from time.Time, to time.Time) (events []dueEvent) {
+	due, err := cj.scheduling.DueCommands(from, to)
+	if err != nil {
+		cj.logger.WithError(err).Error("Cannot list due scheduled tasks")
+	}
+	for _, d := range due {
+		d := d
+		events = append(events, dueEvent{at: d.At, command: &d.Command, skip: func() bool {
+			return cj.scheduling.SkipRun(d.ID, d.At)
+		}})
+	}
+
+	if cj.customStatus != nil && cj.pollInterval > 0 && !cj.pollStart.IsZero() {
+		if earliest := to.Add(-maxPolls * cj.pollInterval); from.Before(earliest) {
+			from = earliest
 		}
-		if len(c.Fields) == 1 {
-			cj.getPowerStatus(c)
-		} else {
-			k := functions.GetKeys(controllerActions)
-			subcommand := strings.ToLower(c.Fields[1])
-			if functions.Contains(k, subcommand) {
-				f := controllerActions[subcommand]
-				f(c)
-			} else {
-				cj.errorMsg(c.Fields, c.Channel, "I'm not sure what you sayin")
-			}
+		n := from.Sub(cj.pollStart)/cj.pollInterval + 1
+		if n < 1 {
+			n = 1
+		}
+		for at := cj.pollStart.Add(n * cj.pollInterval); !at.After(to); at = at.Add(cj.pollInterval) {
+			events = append(events, dueEvent{at: at, run: cj.poll})
 		}
-	} else {
-		slack.PostMessage(c.Channel, "The "+cj.name+" is disabled")
 	}
+	events = append(events, cj.oneShotDueEvents(from, to)...)
+	return append(events, cj.autoOffDueEvents(from, to)...)
 }
 
 func (cj *controllerJob) checkCreateBucket() (exists bool) {
@@ -104,7 +245,7 @@ func (cj *controllerJob) loadSchedsFromDB() (err error) {
 	records, err := cj.scheduling.LoadSchedsfromDB()
 	if err != nil {
 		message := "Cannot load schedules from database"
-		slack.Message(message)
+		cj.messenger.Message(message)
 		cj.logger.WithFields(log.Fields{
 			"err":  err,
 			"path": cj.scheduling.DbPath,
@@ -114,12 +255,12 @@ func (cj *controllerJob) loadSchedsFromDB() (err error) {
 
 	for _, record := range records {
 		powerVal := record.Command.Fields[2]
-		e := cj.scheduling.ContSet(record.ID, record.CronExp, record.Command, false)
+		e := cj.scheduling.ContLoad(record)
 		if e != nil {
-			cj.errorMsg(record.Command.Fields, record.Command.Channel, err.Error())
+			cj.errorMsg(record.Command.Fields, record.Command.Channel, e.Error())
 			err = e
 		} else {
-			slack.Message("_Loaded scheduled power " + powerVal + " task from the database._")
+			cj.messenger.Message("_Loaded scheduled power " + powerVal + " task from the database._")
 			cj.logger.WithFields(log.Fields{
 				"id":       record.ID,
 				"powerval": powerVal,
@@ -163,6 +304,9 @@ func (cj *controllerJob) updatePowerStateInDB() (err error) {
 	if err == nil {
 		err = db.AddValue(cj.dbPath, "lastPowerOn", buf)
 	}
+	if err == nil {
+		err = cj.saveAutoOff()
+	}
 
 	return err
 }
@@ -182,6 +326,9 @@ func (cj *controllerJob) loadPowerStateFromDB() (err error) {
 			} else {
 				cj.lastPowerOn = lastPowerOn
 			}
+			if e := cj.loadAutoOff(); e != nil {
+				cj.logger.WithError(e).Error("Cannot load the auto-off time from db")
+			}
 
 			if cj.scheduling.Set {
 				cj.scheduling.ModifyPowerMessage(cj.name, cj.powerState)
@@ -194,165 +341,530 @@ func (cj *controllerJob) loadPowerStateFromDB() (err error) {
 }
 
 func (cj *controllerJob) powerControl(c slack.CommandInfo, powerState string, force bool) {
+	if cj.members != nil {
+		cj.groupPowerControl(c, powerState, force)
+		return
+	}
+	if cj.powerState == powerState && !force {
+		message := "The " + cj.machineName + " is already " + powerState
+		go cj.logger.Info(message)
+		cj.messenger.PostMessage(c.Channel, message)
+	} else {
+		err := cj.power(c, powerState, commandSource(c, force), force && isAdmin(c.User))
+		cj.slackPowerResponse(powerState, err, c)
+	}
+}
+
+func (cj *controllerJob) power(c slack.CommandInfo, powerState string, source string, override bool) error {
+	err := cj.switchDevice(c, powerState, source, override)
+	if err == nil {
+		cj.lastPowerOn = functions.Now()
+		cj.setPowerState(powerState, true)
+	}
+	return err
+}
+
+// switchDevice calls the driver once the interlocks allow it, or an admin
+// overrides them, and records the outcome.
+func (cj *controllerJob) switchDevice(c slack.CommandInfo, powerState string, source string, override bool) error {
 	powerFunctions := map[string]func() error{
 		"on":  cj.customOn,
 		"off": cj.customOff,
 	}
-	numParams := 2
-	if force {
-		numParams = 3
+	cascade, err := cj.interlocks.check(cj, powerState)
+	var ie *interlockError
+	switch {
+	case errors.As(err, &ie) && override:
+		go cj.logger.WithError(err).WithField("user", c.User).Warn("Interlock overridden by an admin")
+		cj.messenger.Message("_<@" + c.User + "> forced the " + cj.machineName + " " + powerState + " past an interlock: " + err.Error() + "_")
+		err = nil
+	case errors.As(err, &ie):
+		ie.forced = source == sourceForce
 	}
-	if commandCheck(c, numParams, cj.logger) {
-		if cj.powerState == powerState && !force {
-			message := "The " + cj.machineName + " is already " + powerState
-			go cj.logger.Info(message)
-			slack.PostMessage(c.Channel, message)
-		} else {
-			err := powerFunctions[powerState]()
-			cj.lastPowerOn = time.Now()
-			cj.powerState = powerState
-			if cj.scheduling.Set {
-				cj.scheduling.ModifyPowerMessage(cj.name, cj.powerState)
-			}
-			cj.slackPowerResponse(cj.powerState, err, c)
-			cj.updatePowerStateInDB()
+	if err == nil {
+		err = cj.cascadeOff(cascade)
+	}
+	if err == nil {
+		err = powerFunctions[powerState]()
+	}
+	cj.recordPowerEvent(powerState, source, c.User, err)
+	args := powerState
+	if source != sourceUser {
+		args += " (" + source + ")"
+	}
+	cj.audit(c, "power", args, err)
+	return err
+}
+
+// pollDevice asks the device for its state every poll interval until stop
+// is closed.
+func (cj *controllerJob) pollDevice(stop chan struct{}) {
+	ticker := time.NewTicker(cj.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cj.poll()
+		case <-stop:
+			return
 		}
 	}
 }
 
-func (cj *controllerJob) TurnOn(c slack.CommandInfo) {
-	cj.powerControl(c, "on", false)
+func (cj *controllerJob) poll() {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	if cj.active {
+		cj.reconcile(cj.customStatus())
+	}
 }
 
-func (cj *controllerJob) TurnOff(c slack.CommandInfo) {
-	cj.powerControl(c, "off", false)
+func (cj *controllerJob) deviceStateChanged(state string) {
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	cj.reconcile(state, nil)
 }
 
-func (cj *controllerJob) turnOnForce(c slack.CommandInfo) {
-	cj.powerControl(c, "on", true)
+// reconcile compares what the device reports with the recorded power state,
+// which goes stale when someone uses the device's own switch. A device that
+// can't be reached is "unknown" until it answers again; the database keeps
+// the last known state.
+func (cj *controllerJob) reconcile(state string, err error) {
+	switch {
+	case errors.Is(err, drivers.ErrNotSupported), errors.Is(err, drivers.ErrChanging):
+		return
+	case err != nil:
+		if cj.powerState != "unknown" {
+			message := "Couldn't reach the " + cj.machineName + ", its state is unknown"
+			go cj.logger.WithError(err).Warn(message)
+			cj.messenger.Message(message)
+			cj.knownState = cj.powerState
+			cj.recordPowerEvent("unknown", sourceDrift, "", err)
+			cj.setPowerState("unknown", false)
+		}
+		return
+	case state == cj.powerState:
+		return
+	}
+
+	var message string
+	if cj.powerState == "unknown" {
+		message = "Reached the " + cj.machineName + " again, it is " + state
+	} else {
+		message = "The " + cj.machineName + " was turned " + state + " outside the bot"
+	}
+	go cj.logger.WithFields(log.Fields{
+		"recorded": cj.powerState,
+		"device":   state,
+	}).Info(message)
+
+	cj.recordPowerEvent(state, sourceDrift, "", nil)
+	if state == "on" && !(cj.powerState == "unknown" && cj.knownState == "on") {
+		cj.lastPowerOn = functions.Now()
+	}
+	cj.setPowerState(state, true)
+	cj.messenger.Message(message + cj.autoOffStatus())
+	if state == "off" {
+		cj.interlockDrift()
+	}
 }
 
-func (cj *controllerJob) turnOffForce(c slack.CommandInfo) {
-	cj.powerControl(c, "off", true)
+func (cj *controllerJob) setPowerState(state string, save bool) {
+	cj.powerState = state
+	cj.interlocks.setState(cj, state)
+	cj.startAutoOff()
+	if cj.scheduling.Set {
+		cj.scheduling.ModifyPowerMessage(cj.name, cj.powerState)
+	}
+	if save {
+		cj.updatePowerStateInDB()
+	}
 }
 
-func (cj *controllerJob) forcePower(c slack.CommandInfo) {
-	forceActions := map[string]action{
-		"on":  cj.turnOnForce,
-		"off": cj.turnOffForce,
+func (cj *controllerJob) TurnOn(c slack.CommandInfo, a args) {
+	if a.has("when") {
+		cj.scheduleOneShot(c, "on", strings.Fields(a.word("when")))
+		return
 	}
-	if len(c.Fields) == 2 {
-		cj.errorMsg(c.Fields, c.Channel, "Force on or off?")
-	} else if len(c.Fields) > 2 {
-		k := functions.GetKeys(forceActions)
-		subcommand := strings.ToLower(c.Fields[2])
-		if functions.Contains(k, subcommand) {
-			f := forceActions[subcommand]
-			f(c)
-		} else {
-			cj.errorMsg(c.Fields, c.Channel, "I'm not sure what you sayin")
-		}
+	cj.powerControl(c, "on", false)
+}
+
+func (cj *controllerJob) TurnOff(c slack.CommandInfo, a args) {
+	if a.has("when") {
+		cj.scheduleOneShot(c, "off", strings.Fields(a.word("when")))
+		return
 	}
+	cj.powerControl(c, "off", false)
+}
+
+func (cj *controllerJob) turnOnForce(c slack.CommandInfo, a args) {
+	cj.powerControl(c, "on", true)
+}
+
+func (cj *controllerJob) turnOffForce(c slack.CommandInfo, a args) {
+	cj.powerControl(c, "off", true)
 }
 
 func (cj *controllerJob) slackPowerResponse(status string, err error, c slack.CommandInfo) {
-	if err != nil {
+	var ie *interlockError
+	if errors.As(err, &ie) {
+		go cj.logger.WithError(err).Warn("Interlock blocked a power command")
+		cj.messenger.PostMessage(c.Channel, ie.message())
+	} else if err != nil {
 		message := "Couldn't turn " + status + " the " + cj.machineName
 		go cj.logger.WithField("err", err).Error(message)
-		slack.Message(message)
+		cj.messenger.Message(message + "\n_" + err.Error() + "_")
 	} else {
 		message := "Turned " + status + " the " + cj.machineName
 		go cj.logger.Info(message)
+		message += cj.autoOffStatus()
 		if c.TimeStamp != "" {
-			slack.React(c.TimeStamp, c.Channel, "ok_hand")
+			cj.messenger.React(c.TimeStamp, c.Channel, "ok_hand")
 		}
-		slack.Message(message)
+		cj.messenger.Message(message)
 	}
 }
 
-func (cj *controllerJob) getPowerStatus(c slack.CommandInfo) {
-	if commandCheck(c, 2, cj.logger) {
-		message := "The " + cj.machineName + " is "
-		if cj.powerState == "on" {
-			uptime := time.Since(cj.lastPowerOn).Round(time.Second)
-			message += "*on*\nUptime: " + fmt.Sprint(uptime)
-		} else {
-			message += "*off*"
+func (cj *controllerJob) getPowerStatus(c slack.CommandInfo, a args) {
+	if cj.members != nil {
+		cj.groupStatus(c)
+		return
+	}
+	var message string
+	if cj.customStatus != nil {
+		state, err := cj.customStatus()
+		if errors.Is(err, drivers.ErrChanging) {
+			message += "_The " + cj.machineName + " is still switching, showing the state it was set to_\n"
 		}
-		message += "\n" + cj.scheduling.ContGetSchedulingStatus()
+		cj.reconcile(state, err)
+	}
 
-		slack.PostMessage(c.Channel, message)
+	message += "The " + cj.machineName + " is "
+	switch cj.powerState {
+	case "on":
+		uptime := functions.Now().Sub(cj.lastPowerOn).Round(time.Second)
+		message += "*on*\nUptime: " + fmt.Sprint(uptime) + cj.autoOffStatus()
+	case "unknown":
+		message += "*unknown*, it can't be reached"
+		if cj.knownState != "" {
+			message += "\nLast known state: *" + cj.knownState + "*"
+		}
+	default:
+		message += "*off*"
 	}
+	if meter, ok := cj.device.(drivers.PowerMeter); ok && cj.powerState != "unknown" {
+		watts, err := meter.Power()
+		if err == nil {
+			message += fmt.Sprintf("\nPower draw: %.1f W", watts)
+		} else if err != drivers.ErrNotSupported {
+			go cj.logger.WithError(err).Warn("Cannot read the power draw")
+		}
+	}
+	message += "\n" + cj.schedulingStatus(false)
+
+	cj.messenger.PostMessage(c.Channel, message)
+}
+
+func (cj *controllerJob) health() (problem string) {
+	if cj.members != nil {
+		return ""
+	}
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+	if cj.powerState == "unknown" {
+		return "can't reach the " + cj.machineName
+	}
+	return ""
 }
 
 func (cj *controllerJob) errorMsg(fields []string, channel string, message string) {
 	go cj.logger.WithField("fields", fields).Warn(message)
-	slack.PostMessage(channel, message)
+	cj.messenger.PostMessage(channel, message)
 }
 
 func (cj *controllerJob) sendMsg(channel string, message string) {
 	go cj.logger.Info(message)
-	slack.PostMessage(channel, message)
-}
-
-func (cj *controllerJob) scheduleHandler(c slack.CommandInfo) {
-	schedulingActions := map[string]action{
-		"on":     cj.sched,
-		"off":    cj.sched,
-		"status": cj.sendSchedulingStatus,
-	}
-	if len(c.Fields) == 2 {
-		cj.sendSchedulingStatus(c)
-	} else if len(c.Fields) > 2 {
-		k := functions.GetKeys(schedulingActions)
-		subcommand := strings.ToLower(c.Fields[2])
-		if functions.Contains(k, subcommand) {
-			f := schedulingActions[subcommand]
-			f(c)
-		} else {
-			cj.errorMsg(c.Fields, c.Channel, "I'm not sure what you sayin")
+	cj.messenger.PostMessage(channel, message)
+}
+
+// sched sets up a schedule with "<on/off> set <cron or phrase> [as <label>]".
+// Phrases like "every weekday at 8am" are translated to cron and only saved
+// once they are confirmed.
+func (cj *controllerJob) sched(powerVal string) func(c slack.CommandInfo, a args) {
+	return func(c slack.CommandInfo, a args) {
+		cronFields, label := strings.Fields(a.word("schedule")), ""
+		for i, field := range cronFields {
+			if strings.ToLower(field) == "as" {
+				cronFields, label = cronFields[:i], strings.Trim(strings.Join(cronFields[i+1:], " "), "\"“”")
+				break
+			}
+		}
+		cronExp, phrase, err := scheduling.ParseSchedule(strings.Join(cronFields, " "))
+		if err != nil {
+			cj.errorMsg(c.Fields, c.Channel, err.Error()+"\nUse cron or a phrase like `every weekday at 8am`")
+			return
+		}
+		if phrase {
+			cj.confirmSched(c, powerVal, cronExp, label)
+			return
 		}
+		cj.saveSched(c, powerVal, cronExp, label)
 	}
 }
 
-func (cj *controllerJob) sched(c slack.CommandInfo) {
-	powerVal := c.Fields[2]
-	// keyword = c.Fields[0]
-	if len(c.Fields) >= 4 {
-		if c.Fields[3] == "set" && len(c.Fields) > 4 {
-			cronExp := strings.Join(c.Fields[4:], " ")
-			idNum, err := db.IncrementBucketInteger(cj.scheduling.DbPath)
-			idString := strconv.Itoa(idNum) + c.Fields[0] + "controller"
-			id := functions.SHA256Sum(idString, controllerIDLen)
-			if err != nil {
-				cj.errorMsg(c.Fields, c.Channel, "couldn't get ID for schedule")
-			}
+// removeSchedByPower removes the only schedule for a power value.
+func (cj *controllerJob) removeSchedByPower(powerVal string) func(c slack.CommandInfo, a args) {
+	return func(c slack.CommandInfo, a args) {
+		cj.removeSchedByRef(c, powerVal)
+	}
+}
 
-			newSched := cj.scheduling.Set
+type pendingSched struct {
+	command slack.CommandInfo
+	power   string
+	cronExp string
+	label   string
+}
 
-			err = cj.scheduling.ContSet(id, cronExp, c, true)
-			if err != nil {
-				cj.errorMsg(c.Fields, c.Channel, err.Error())
-			} else {
-				cj.sendMsg(c.Channel, "_Successfully scheduled power "+powerVal+" task._\n"+cj.scheduling.ContGetSchedulingStatus())
-				if !newSched {
-					cj.scheduling.PostPowerMessage(c.Channel, cj.name, cj.powerState)
-				}
-			}
+// confirmSched shows what a schedule phrase was taken to mean and holds on to
+// it until the same person confirms it.
+func (cj *controllerJob) confirmSched(c slack.CommandInfo, powerVal string, cronExp string, label string) {
+	description, next, err := scheduling.DescribeCron(cronExp, 3)
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	if cj.pendingScheds == nil {
+		cj.pendingScheds = make(map[string]pendingSched)
+	}
+	cj.pendingScheds[c.User] = pendingSched{command: c, power: powerVal, cronExp: cronExp, label: label}
+
+	var runs []string
+	for _, t := range next {
+		runs = append(runs, t.Format("Mon Jan 2 3:04 PM"))
+	}
+	cj.sendMsg(c.Channel, "_Power "+powerVal+": "+description+"_ (`"+cronExp+"`)\n"+
+		"Next runs: "+strings.Join(runs, ", ")+"\n"+
+		"`@lab-bot "+cj.keyword+" schedule confirm` saves it.")
+}
+
+func (cj *controllerJob) confirmPendingSched(c slack.CommandInfo, a args) {
+	pending, ok := cj.pendingScheds[c.User]
+	if !ok {
+		cj.errorMsg(c.Fields, c.Channel, "There's no schedule waiting to be confirmed")
+		return
+	}
+	delete(cj.pendingScheds, c.User)
+	cj.saveSched(pending.command, pending.power, pending.cronExp, pending.label)
+}
+
+func (cj *controllerJob) saveSched(c slack.CommandInfo, powerVal string, cronExp string, label string) {
+	idNum, err := db.IncrementBucketInteger(cj.scheduling.DbPath)
+	idString := strconv.Itoa(idNum) + c.Fields[0] + "controller"
+	id := functions.SHA256Sum(idString, controllerIDLen)
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, "couldn't get ID for schedule")
+		return
+	}
+
+	newSched := cj.scheduling.Set
+
+	err = cj.scheduling.ContSet(id, cronExp, label, c, true)
+	cj.audit(c, "schedule", "add "+id+" power "+powerVal+" at "+cronExp, err)
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+	} else {
+		cj.sendMsg(c.Channel, "_Successfully scheduled power "+powerVal+" task `"+id+"`._\n"+cj.schedulingStatus(false))
+		if !newSched {
+			cj.scheduling.PostPowerMessage(c.Channel, cj.name, cj.powerState)
+		}
+	}
+}
+
+func (cj *controllerJob) removeSched(c slack.CommandInfo, a args) {
+	ref := a.word("schedule")
+	if cj.removeOneShot(ref) {
+		cj.audit(c, "schedule", "remove "+ref, nil)
+		cj.sendMsg(c.Channel, "_Cancelled one-shot task `"+ref+"`._\n"+cj.schedulingStatus(false))
+		return
+	}
+	cj.removeSchedByRef(c, ref)
+}
+
+func (cj *controllerJob) removeSchedByRef(c slack.CommandInfo, ref string) {
+	id, err := cj.scheduling.ContFind(ref)
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	powerVal := cj.scheduling.Sched[id].Power
+	err = cj.scheduling.ContRemove(id)
+	cj.audit(c, "schedule", "remove "+id, err)
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	cj.sendMsg(c.Channel, "_Successfully removed power "+powerVal+" task `"+id+"`._\n"+cj.schedulingStatus(false))
+}
+
+// schedHolidays sets whether a schedule is skipped on holidays, with
+// "holidays <id/label> respect/ignore".
+func (cj *controllerJob) schedHolidays(c slack.CommandInfo, a args) {
+	modes := map[string]bool{"respect": true, "ignore": false}
+	mode := strings.ToLower(a.word("mode"))
+	respect, ok := modes[mode]
+	if !ok {
+		cj.errorMsg(c.Fields, c.Channel, "Should the schedule respect or ignore holidays?")
+		return
+	}
+	id, err := cj.scheduling.ContFind(a.word("schedule"))
+	if err == nil {
+		err = cj.scheduling.ContSetHolidays(id, respect)
+		cj.audit(c, "schedule", "holidays "+id+" "+mode, err)
+	}
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	message := "_Scheduled task `" + id + "` is skipped on holidays._"
+	if !respect {
+		message = "_Scheduled task `" + id + "` runs on holidays too._"
+	}
+	cj.sendMsg(c.Channel, message+"\n"+cj.schedulingStatus(false))
+}
+
+// schedRefs finds the schedule a command refers to, or all of them when it
+// doesn't name one.
+func (cj *controllerJob) schedRefs(ref string) (ids []string, err error) {
+	if ref == "" {
+		ids = cj.scheduling.ContIDs()
+		if len(ids) == 0 {
+			return nil, errors.New("there are no scheduled tasks")
+		}
+		return ids, nil
+	}
+	id, err := cj.scheduling.ContFind(ref)
+	return []string{id}, err
+}
+
+func tasksString(ids []string) string {
+	switch len(ids) {
+	case 1:
+		return "scheduled task `" + ids[0] + "`"
+	case 2:
+		return "both scheduled tasks"
+	}
+	return "all " + strconv.Itoa(len(ids)) + " scheduled tasks"
+}
+
+// pauseScheds pauses a schedule, or all of them, with
+// "pause [id/label] [until <day>]".
+func (cj *controllerJob) pauseScheds(c slack.CommandInfo, a args) {
+	fields := strings.Fields(a.word("schedule"))
+	var until *time.Time
+	for i, field := range fields {
+		if strings.ToLower(field) != "until" {
+			continue
+		}
+		now := functions.Now()
+		day, err := functions.ParseDay(strings.Join(fields[i+1:], " "), now)
+		if err != nil {
+			cj.errorMsg(c.Fields, c.Channel, "Until when? Like `"+cj.keyword+" schedule pause until 2026-01-05` or `until monday`")
 			return
-		} else if c.Fields[3] == "remove" && len(c.Fields) == 4 {
-			err := cj.scheduling.ContRemove(c)
-			if err != nil {
-				cj.errorMsg(c.Fields, c.Channel, err.Error())
-			} else {
-				cj.sendMsg(c.Channel, "_Successfully removed power "+powerVal+" task._\n"+cj.scheduling.ContGetSchedulingStatus())
+		}
+		if !day.After(now) {
+			cj.errorMsg(c.Fields, c.Channel, "The pause has to end after today")
+			return
+		}
+		until = &day
+		fields = fields[:i]
+		break
+	}
+
+	ids, err := cj.schedRefs(strings.Join(fields, " "))
+	for _, id := range ids {
+		if err == nil {
+			err = cj.scheduling.ContPause(id, until)
+		}
+	}
+	if len(ids) > 0 {
+		cj.audit(c, "schedule", "pause "+strings.Join(ids, " "), err)
+	}
+	cj.scheduling.RefreshPowerMessage()
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	message := "_Paused " + tasksString(ids)
+	if until != nil {
+		message += " until " + until.Format("Mon Jan 2")
+	}
+	cj.sendMsg(c.Channel, message+"._\n"+cj.schedulingStatus(false))
+}
+
+func (cj *controllerJob) resumeScheds(c slack.CommandInfo, a args) {
+	ids, err := cj.schedRefs(a.word("schedule"))
+	var resumed []string
+	for _, id := range ids {
+		var r bool
+		if err == nil {
+			r, err = cj.scheduling.ContResume(id)
+		}
+		if r {
+			resumed = append(resumed, id)
+		}
+	}
+	cj.scheduling.RefreshPowerMessage()
+	if len(resumed) > 0 || err != nil && len(ids) > 0 {
+		cj.audit(c, "schedule", "resume "+strings.Join(resumed, " "), err)
+	}
+	switch {
+	case err != nil:
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+	case len(resumed) == 0:
+		cj.errorMsg(c.Fields, c.Channel, "There's nothing paused or skipped to resume")
+	default:
+		cj.sendMsg(c.Channel, "_Resumed "+tasksString(resumed)+"._\n"+cj.schedulingStatus(false))
+	}
+}
+
+// skipNextSched skips the next run of a schedule, or the run that comes
+// next of all of them.
+func (cj *controllerJob) skipNextSched(c slack.CommandInfo, a args) {
+	ids, err := cj.schedRefs(a.word("schedule"))
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	id := ids[0]
+	if len(ids) > 1 {
+		var soonest time.Time
+		for _, i := range ids {
+			next := cj.scheduling.NextRun(i)
+			if !next.IsZero() && (soonest.IsZero() || next.Before(soonest)) {
+				id, soonest = i, next
 			}
+		}
+		if soonest.IsZero() {
+			cj.errorMsg(c.Fields, c.Channel, "All the schedules are paused, there is no run to skip")
 			return
 		}
 	}
-	cj.errorMsg(c.Fields, c.Channel, "Malformed scheduling command")
+	at, err := cj.scheduling.ContSkipNext(id)
+	cj.audit(c, "schedule", "skip-next "+id, err)
+	cj.scheduling.RefreshPowerMessage()
+	if err != nil {
+		cj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	cj.sendMsg(c.Channel, "_Skipping the scheduled power "+cj.scheduling.Sched[id].Power+" at "+
+		at.Format("Mon Jan 2 3:04 PM")+" (`"+id+"`)._\n"+cj.schedulingStatus(false))
+}
+
+func (cj *controllerJob) listScheds(c slack.CommandInfo, a args) {
+	cj.messenger.PostMessage(c.Channel, cj.schedulingStatus(true))
 }
 
-func (cj *controllerJob) sendSchedulingStatus(c slack.CommandInfo) {
-	slack.PostMessage(c.Channel, cj.scheduling.ContGetSchedulingStatus())
+func (cj *controllerJob) sendSchedulingStatus(c slack.CommandInfo, a args) {
+	cj.messenger.PostMessage(c.Channel, cj.schedulingStatus(false))
 }
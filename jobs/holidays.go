@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishhvaan/lab-bot/functions"
+	"github.com/vishhvaan/lab-bot/scheduling"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+const (
+	upcomingClosures = 5
+	skippedRunsShown = 5
+	skippedRunsAge   = 7 * 24 * time.Hour
+)
+
+type holidayJob struct {
+	labJob
+}
+
+func (hj *holidayJob) init() {
+	hj.labJob.init()
+
+	err := hj.holidays.Load([]string{"jobs", "holidays"})
+	if err != nil {
+		hj.logger.WithError(err).Error(hj.name + " cannot load closures from the database")
+	}
+	upcoming := len(hj.holidays.Upcoming())
+	hj.logger.Info(hj.name + " loaded")
+	hj.messenger.Message(hj.name + " loaded. " + strconv.Itoa(upcoming) + " closures coming up.")
+}
+
+func (hj *holidayJob) requiredRoles() map[string][]string {
+	return map[string][]string{
+		"add":    {adminRole},
+		"remove": {adminRole},
+	}
+}
+
+func (hj *holidayJob) commands() commandSet {
+	return commandSet{
+		{desc: "Tells whether the lab is closed today, and what closures are next", run: hj.status},
+		{name: "status", desc: "Tells whether the lab is closed today, and what closures are next", run: hj.status},
+		{name: "list", desc: "Lists the closures coming up with their IDs", run: hj.list},
+		{name: "add", args: []argSpec{word("days"), text("name").opt()},
+			desc: "Closes the lab on a day or days, like `2026-12-24..2027-01-02 winter break`", run: hj.add},
+		{name: "remove", args: []argSpec{word("day")}, desc: "Removes a closure added in chat, by its first day", run: hj.remove},
+	}
+}
+
+func (hj *holidayJob) commandProcessor(c slack.CommandInfo) {
+	if hj.active {
+		hj.runCommand(hj.commands(), c)
+	} else {
+		hj.messenger.PostMessage(c.Channel, "The "+hj.name+" is disabled")
+	}
+}
+
+// status tells whether the lab is closed today, what closures are next and
+// which scheduled runs were skipped lately.
+func (hj *holidayJob) status(c slack.CommandInfo, a args) {
+	now := functions.Now()
+	var b strings.Builder
+	if closure, closed := hj.holidays.Closed(now); closed {
+		b.WriteString("The lab is *closed* today: " + closure.String())
+	} else {
+		b.WriteString("The lab is *open* today")
+	}
+
+	upcoming := hj.holidays.Upcoming()
+	if len(upcoming) > upcomingClosures {
+		upcoming = upcoming[:upcomingClosures]
+	}
+	b.WriteString("\n*Coming up*:")
+	if len(upcoming) == 0 {
+		b.WriteString(" none")
+	}
+	for _, closure := range upcoming {
+		b.WriteString("\n" + closure.String())
+	}
+
+	skipped := hj.holidays.Skipped("", now.Add(-skippedRunsAge))
+	if len(skipped) > skippedRunsShown {
+		skipped = skipped[len(skipped)-skippedRunsShown:]
+	}
+	if len(skipped) > 0 {
+		b.WriteString("\n*Skipped*:")
+	}
+	for _, run := range skipped {
+		b.WriteString("\n" + describeSkippedRun(run))
+	}
+	hj.messenger.PostMessage(c.Channel, b.String())
+}
+
+func describeSkippedRun(run scheduling.SkippedRun) string {
+	what := run.What
+	if run.Job != "birthday" {
+		what = run.Job + " " + what
+	}
+	text := "_" + what + " at " + run.At.Format("Mon Jan 2 3:04 PM") + "_"
+	if run.Closure.Name != "" {
+		text += " for " + run.Closure.Name
+	}
+	return text
+}
+
+func (hj *holidayJob) list(c slack.CommandInfo, a args) {
+	upcoming := hj.holidays.Upcoming()
+	if len(upcoming) == 0 {
+		hj.messenger.PostMessage(c.Channel, "There are no closures coming up")
+		return
+	}
+	var lines []string
+	for _, closure := range upcoming {
+		line := "`" + closure.ID() + "` " + closure.String()
+		if closure.Fixed() {
+			line += " _from the config_"
+		}
+		lines = append(lines, line)
+	}
+	hj.messenger.PostMessage(c.Channel, strings.Join(lines, "\n"))
+}
+
+func (hj *holidayJob) add(c slack.CommandInfo, a args) {
+	closure, err := scheduling.ParseClosure(strings.TrimSpace(a.word("days") + " " + a.word("name")))
+	if err == nil {
+		err = hj.holidays.Add(closure)
+		hj.audit(c, "closure", "add "+closure.String(), err)
+	}
+	if err != nil {
+		hj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	hj.sendMsg(c.Channel, "_Added "+closure.String()+" to the holiday calendar._")
+}
+
+func (hj *holidayJob) remove(c slack.CommandInfo, a args) {
+	closure, err := hj.holidays.Remove(a.word("day"))
+	hj.audit(c, "closure", "remove "+a.word("day"), err)
+	if err != nil {
+		hj.errorMsg(c.Fields, c.Channel, err.Error())
+		return
+	}
+	hj.sendMsg(c.Channel, "_Removed "+closure.String()+" from the holiday calendar._")
+}
+
+func (hj *holidayJob) errorMsg(fields []string, channel string, message string) {
+	go hj.logger.WithField("fields", fields).Warn(message)
+	hj.messenger.PostMessage(channel, message)
+}
+
+func (hj *holidayJob) sendMsg(channel string, message string) {
+	go hj.logger.Info(message)
+	hj.messenger.PostMessage(channel, message)
+}
@@ -3,9 +3,7 @@ package jobs
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
 
-	"github.com/vishhvaan/lab-bot/functions"
 	"github.com/vishhvaan/lab-bot/slack"
 )
 
@@ -21,79 +19,56 @@ func (lm *labMeetingJob) init() {
 	// lm.loadlabMeetingGroupsFromDB()
 }
 
-func (lm *labMeetingJob) commandProcessor(c slack.CommandInfo) {
-	if lm.active {
-		controllerActions := map[string]action{
-			"groups":  lm.groupsHandler,
-			"present": lm.presentHandler,
-		}
-		if len(c.Fields) == 1 {
-			lm.printlabMeetingGroups(c)
-		} else {
-			k := functions.GetKeys(controllerActions)
-			subcommand := strings.ToLower(c.Fields[1])
-			if functions.Contains(k, subcommand) {
-				f := controllerActions[subcommand]
-				f(c)
-			} else {
-				lm.errorMsg(c.Fields, c.Channel, "I'm not sure what you sayin")
-			}
-		}
-	} else {
-		slack.PostMessage(c.Channel, "The "+lm.name+" is disabled")
+func (lm *labMeetingJob) commands() commandSet {
+	return commandSet{
+		{desc: "Shows the lab meeting groups", run: lm.printlabMeetingGroups},
+		{name: "groups", desc: "Shows the lab meeting groups", run: lm.printlabMeetingGroups},
+		{name: "groups json", desc: "Shows the lab meeting groups as JSON", run: lm.printlabMeetingGroupsJSON},
+		{name: "groups update", args: []argSpec{text("json")},
+			desc: "Replaces the groups with a JSON object of group names to members", run: lm.parselabMeetingGroups},
+		{name: "present", desc: "Picks who presents next", run: lm.presentHandler},
 	}
 }
 
-func (lm *labMeetingJob) groupsHandler(c slack.CommandInfo) {
-	controllerActions := map[string]action{
-		"json":   lm.printlabMeetingGroupsJSON,
-		"update": lm.parselabMeetingGroups,
-	}
-	if len(c.Fields) == 2 {
-		lm.printlabMeetingGroups(c)
+func (lm *labMeetingJob) commandProcessor(c slack.CommandInfo) {
+	if lm.active {
+		lm.runCommand(lm.commands(), c)
 	} else {
-		k := functions.GetKeys(controllerActions)
-		subcommand := strings.ToLower(c.Fields[2])
-		if functions.Contains(k, subcommand) {
-			f := controllerActions[subcommand]
-			f(c)
-		} else {
-			lm.errorMsg(c.Fields, c.Channel, "I'm not sure what you sayin")
-		}
+		lm.messenger.PostMessage(c.Channel, "The "+lm.name+" is disabled")
 	}
 }
 
-func (lm *labMeetingJob) presentHandler(c slack.CommandInfo) {
+func (lm *labMeetingJob) presentHandler(c slack.CommandInfo, a args) {
 
 }
 
-func (lm *labMeetingJob) parselabMeetingGroups(c slack.CommandInfo) {
-	if len(c.Fields) >= 3 {
-		groupsJSON := strings.Join(c.Fields[4:], " ")
-		err := json.Unmarshal([]byte(groupsJSON), &lm.labMeetingGroups)
-		if err != nil {
-			go lm.logger.WithField("command", groupsJSON).WithError(err).Warn("Cannot unmarshal json from message")
-			slack.PostMessage(c.Channel, "Cannot parse groups from the input JSON")
-			return
-		}
+func (lm *labMeetingJob) parselabMeetingGroups(c slack.CommandInfo, a args) {
+	groupsJSON := a.word("json")
+	groups := make(map[string][]string)
+	err := json.Unmarshal([]byte(groupsJSON), &groups)
+	if err != nil {
+		go lm.logger.WithField("command", groupsJSON).WithError(err).Warn("Cannot unmarshal json from message")
+		lm.messenger.PostMessage(c.Channel, "Cannot parse groups from the input JSON")
+		return
 	}
-	lm.errorMsg(c.Fields, c.Channel, "Malformed groups update command")
+	lm.labMeetingGroups = groups
+	lm.sendMsg(c.Channel, "Updated the lab meeting groups")
 }
 
 // func (lm *LabMeetingJob) loadlabMeetingGroupsFromDB() {
 
 // }
 
-func (lm *labMeetingJob) printlabMeetingGroups(c slack.CommandInfo) {
-	if lm.labMeetingGroups != nil && len(lm.labMeetingGroups) == 0 {
+func (lm *labMeetingJob) printlabMeetingGroups(c slack.CommandInfo, a args) {
+	if len(lm.labMeetingGroups) > 0 {
 		lm.sendMsg(c.Channel, "Lab Meeting Groups: "+fmt.Sprint(lm.labMeetingGroups))
 	} else {
 		lm.errorMsg(c.Fields, c.Channel, "Lab meeting groups are not defined")
 	}
 }
 
-func (lm *labMeetingJob) printlabMeetingGroupsJSON(c slack.CommandInfo) {
-	if lm.labMeetingGroups != nil && len(lm.labMeetingGroups) == 0 {
+func (lm *labMeetingJob) printlabMeetingGroupsJSON(c slack.CommandInfo, a args) {
+	if len(lm.labMeetingGroups) > 0 {
 		str, err := json.Marshal(lm.labMeetingGroups)
 		if err != nil {
 			lm.errorMsg(c.Fields, c.Channel, "Cannot parse internal groups into json")
@@ -107,10 +82,10 @@ func (lm *labMeetingJob) printlabMeetingGroupsJSON(c slack.CommandInfo) {
 
 func (lm *labMeetingJob) sendMsg(channel string, message string) {
 	go lm.logger.Info(message)
-	slack.PostMessage(channel, message)
+	lm.messenger.PostMessage(channel, message)
 }
 
 func (lm *labMeetingJob) errorMsg(fields []string, channel string, message string) {
 	go lm.logger.WithField("fields", fields).Warn(message)
-	slack.PostMessage(channel, message)
+	lm.messenger.PostMessage(channel, message)
 }
@@ -0,0 +1,256 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/config"
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// The jobs job lists the jobs and how they are doing, and lets admins turn
+// them off and on, which is kept in the database so it lasts past a restart.
+// A job can also be loaded again, like after its API key is fixed.
+
+var disabledPath = []string{"jobs", "disabled"}
+
+// stoppableJob is a job with schedulers or timers that have to be stopped
+// before it is loaded again.
+type stoppableJob interface {
+	stop()
+}
+
+// monitoredJob is a job that can tell when something is wrong with it while
+// it runs, like a device it can't reach.
+type monitoredJob interface {
+	health() (problem string)
+}
+
+type jobsJob struct {
+	labJob
+	jh *JobHandler
+}
+
+func newJobsJob(jh *JobHandler) *jobsJob {
+	return &jobsJob{
+		labJob: labJob{
+			name:      "Jobs",
+			keyword:   "jobs",
+			active:    true,
+			desc:      "Lists the jobs and how they are doing, and turns them off and on",
+			logger:    jh.logger.WithFields(log.Fields{"jobtype": "bot", "job": "jobs"}),
+			messenger: jh.messenger,
+		},
+		jh: jh,
+	}
+}
+
+func (jj *jobsJob) requiredRoles() map[string][]string {
+	return map[string][]string{
+		"enable":  {adminRole},
+		"disable": {adminRole},
+		"reload":  {adminRole},
+	}
+}
+
+func (jj *jobsJob) commands() commandSet {
+	return commandSet{
+		{desc: "Lists the jobs and how they are doing", run: jj.listJobs},
+		{name: "list", desc: "Lists the jobs and how they are doing", run: jj.listJobs},
+		{name: "enable", args: []argSpec{word("job")}, desc: "Turns a disabled job back on", run: jj.enableJob},
+		{name: "disable", args: []argSpec{word("job")}, desc: "Turns a job off until it is enabled again, even after a restart", run: jj.disableJob},
+		{name: "reload", args: []argSpec{word("job")}, desc: "Loads a job again, like after fixing its API key", run: jj.reloadJob},
+	}
+}
+
+func (jj *jobsJob) commandProcessor(c slack.CommandInfo) {
+	if !jj.active {
+		jj.messenger.PostMessage(c.Channel, "The "+jj.name+" job is disabled")
+		return
+	}
+	jj.runCommand(jj.commands(), c)
+}
+
+// disabledJobs reads the jobs an admin turned off, and who did.
+func disabledJobs() (disabled map[string]string, err error) {
+	disabled = make(map[string]string)
+	if !db.CheckBucketExists(disabledPath) {
+		return disabled, nil
+	}
+	err = db.RunCallbackOnEachKey(disabledPath, func(key []byte, value []byte) error {
+		disabled[string(key)] = string(value)
+		return nil
+	})
+	return disabled, err
+}
+
+// applyDisabled turns off the jobs an admin disabled, once they are loaded.
+func (jh *JobHandler) applyDisabled() {
+	disabled, err := disabledJobs()
+	if err != nil {
+		jh.logger.WithError(err).Error("Cannot read the disabled jobs")
+	}
+	for key := range disabled {
+		if j, ok := jh.jobs[key]; ok {
+			j.disable()
+		}
+	}
+}
+
+// health tells how a job is doing: disabled, not loaded, a problem it
+// reports itself, or ok.
+func (jh *JobHandler) health(key string, disabled map[string]string) string {
+	if by, ok := disabled[key]; ok {
+		if by == "" {
+			return "disabled"
+		}
+		return "disabled by <@" + by + ">"
+	}
+	j := jh.jobs[key]
+	if jh.failed[key] {
+		return "didn't load"
+	}
+	if !j.isActive() {
+		return "disabled"
+	}
+	if mj, ok := j.(monitoredJob); ok {
+		if problem := mj.health(); problem != "" {
+			return problem
+		}
+	}
+	return "ok"
+}
+
+func (jj *jobsJob) listJobs(c slack.CommandInfo, a args) {
+	disabled, err := disabledJobs()
+	if err != nil {
+		jj.logger.WithError(err).Error("Cannot read the disabled jobs")
+	}
+	var keys []string
+	for key := range jj.jh.jobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := []string{"*Jobs*"}
+	for _, key := range keys {
+		_, desc := describeJob(jj.jh.jobs[key])
+		lines = append(lines, "`"+key+"` : "+desc+", *"+jj.jh.health(key, disabled)+"*")
+	}
+	jj.messenger.PostMessage(c.Channel, strings.Join(lines, "\n"))
+}
+
+// find looks up the job a command names by its keyword or an alias.
+func (jj *jobsJob) find(c slack.CommandInfo, a args) (key string, j job, ok bool) {
+	key, ok = jj.jh.find(a.word("job"))
+	if !ok {
+		jj.errorMsg(c.Fields, c.Channel, "There is no job called "+a.word("job")+", `jobs` lists them")
+		return "", nil, false
+	}
+	return key, jj.jh.jobs[key], true
+}
+
+func (jj *jobsJob) enableJob(c slack.CommandInfo, a args) {
+	key, j, ok := jj.find(c, a)
+	if !ok {
+		return
+	}
+	name, _ := describeJob(j)
+	disabled, err := disabledJobs()
+	if _, ok := disabled[key]; err == nil && !ok {
+		jj.messenger.PostMessage(c.Channel, "The "+name+" isn't disabled")
+		return
+	}
+	if err == nil {
+		err = db.DeleteValue(disabledPath, key)
+	}
+	jj.audit(c, "enable", key, err)
+	if err != nil {
+		jj.logger.WithError(err).Error("Cannot enable job " + key)
+		jj.messenger.PostMessage(c.Channel, "Couldn't enable the "+name)
+		return
+	}
+	if jj.jh.failed[key] {
+		jj.sendMsg(c.Channel, "_Enabled the "+name+", but it didn't load. `jobs reload "+key+"` tries again._")
+		return
+	}
+	j.enable()
+	jj.sendMsg(c.Channel, "_Enabled the "+name+"._")
+}
+
+func (jj *jobsJob) disableJob(c slack.CommandInfo, a args) {
+	key, j, ok := jj.find(c, a)
+	if !ok {
+		return
+	}
+	name, _ := describeJob(j)
+	if j == job(jj) {
+		jj.errorMsg(c.Fields, c.Channel, "The "+name+" job can't be disabled, it's how jobs are enabled again")
+		return
+	}
+	disabled, err := disabledJobs()
+	if _, ok := disabled[key]; err == nil && ok {
+		jj.messenger.PostMessage(c.Channel, "The "+name+" is already disabled")
+		return
+	}
+	if err == nil && !db.CheckBucketExists(disabledPath) {
+		err = db.CreateBucket(disabledPath)
+	}
+	if err == nil {
+		err = db.AddValue(disabledPath, key, []byte(c.User))
+	}
+	jj.audit(c, "disable", key, err)
+	if err != nil {
+		jj.logger.WithError(err).Error("Cannot disable job " + key)
+		jj.messenger.PostMessage(c.Channel, "Couldn't disable the "+name)
+		return
+	}
+	j.disable()
+	jj.sendMsg(c.Channel, "_Disabled the "+name+" until it is enabled again._")
+}
+
+// reloadJob runs the init of a job again, with the secrets read again from
+// their file. A controller connects to its device again and loads its
+// schedules and timers from the database.
+func (jj *jobsJob) reloadJob(c slack.CommandInfo, a args) {
+	key, j, ok := jj.find(c, a)
+	if !ok {
+		return
+	}
+	name, _ := describeJob(j)
+	if err := config.ReloadSecrets(); err != nil {
+		jj.logger.WithError(err).Error("Cannot read the secrets file again")
+		jj.messenger.PostMessage(c.Channel, "Couldn't read the secrets file again, so the "+name+" has the old ones")
+	}
+
+	if sj, ok := j.(stoppableJob); ok {
+		sj.stop()
+	}
+	jj.jh.load(key)
+	disabled, err := disabledJobs()
+	if err != nil {
+		jj.logger.WithError(err).Error("Cannot read the disabled jobs")
+	}
+	if _, ok := disabled[key]; ok {
+		j.disable()
+	}
+	health := jj.jh.health(key, disabled)
+	if health != "ok" {
+		err = errors.New(health)
+	}
+	jj.audit(c, "reload", key, err)
+	jj.sendMsg(c.Channel, "_Reloaded the "+name+": "+health+"._")
+}
+
+func (jj *jobsJob) sendMsg(channel string, message string) {
+	go jj.logger.Info(message)
+	jj.messenger.PostMessage(channel, message)
+}
+
+func (jj *jobsJob) errorMsg(fields []string, channel string, message string) {
+	go jj.logger.WithField("fields", fields).Warn(message)
+	jj.messenger.PostMessage(channel, message)
+}
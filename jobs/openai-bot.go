@@ -2,7 +2,6 @@ package jobs
 
 import (
 	"context"
-	"strings"
 	"time"
 
 	gogpt "github.com/sashabaranov/go-gpt3"
@@ -14,15 +13,29 @@ import (
 
 type openAIBot struct {
 	labJob
-	gptClient        *gogpt.Client
-	gptContext       context.Context
-	defaultTimeout   time.Duration
-	model            string
-	maxTokens        int
-	Temperature      float32
-	TopP             float32
-	FrequencyPenalty float32
-	PresencePenalty  float32
+	gptClient  *gogpt.Client
+	gptContext context.Context
+	options    openAIOptions
+}
+
+type openAIOptions struct {
+	Model            string        `yaml:"model"`
+	MaxTokens        int           `yaml:"max_tokens"`
+	Temperature      float32       `yaml:"temperature"`
+	TopP             float32       `yaml:"top_p"`
+	FrequencyPenalty float32       `yaml:"frequency_penalty"`
+	PresencePenalty  float32       `yaml:"presence_penalty"`
+	Timeout          time.Duration `yaml:"timeout"`
+}
+
+var defaultOpenAIOptions = openAIOptions{
+	Model:            "text-davinci-003",
+	MaxTokens:        1000,
+	Temperature:      0.5,
+	TopP:             0.3,
+	FrequencyPenalty: 0.5,
+	PresencePenalty:  0,
+	Timeout:          10 * time.Second,
 }
 
 func (b *openAIBot) init() {
@@ -30,73 +43,68 @@ func (b *openAIBot) init() {
 
 	if !functions.Contains(functions.GetKeys(config.Secrets), "openai-api-key") {
 		b.logger.Error("OpenAI API Key not found in the secrets file (key is openai-api-key)")
-		go slack.Message("OpenAI API key not found. Disabling response bot.")
+		b.messenger.Message("OpenAI API key not found. Disabling response bot.")
 		b.active = false
 		return
 	}
 
 	b.gptClient = gogpt.NewClient(config.Secrets["openai-api-key"])
 	b.gptContext = context.Background()
-	b.defaultTimeout = 10 * time.Second
-
-	b.model = "text-davinci-003"
-	b.maxTokens = 1000
-	b.Temperature = 0.5
-	b.TopP = 0.3
-	b.FrequencyPenalty = 0.5
-	b.PresencePenalty = 0
 
 	m := "The OpenAI chat bot has been loaded."
-	go slack.Message(m)
+	b.messenger.Message(m)
 	b.logger.Info(m)
 }
 
+func (b *openAIBot) requiredRoles() map[string][]string {
+	return map[string][]string{
+		"modify": {adminRole},
+	}
+}
+
+func (b *openAIBot) commands() commandSet {
+	return commandSet{
+		{args: []argSpec{text("prompt")}, desc: "Answers the prompt", run: b.sendCompletion},
+		{name: "modify", desc: "Changes the model options", run: b.modifyParameters},
+	}
+}
+
 func (b *openAIBot) commandProcessor(c slack.CommandInfo) {
 	if b.active {
-		controllerActions := map[string]action{
-			"modify": b.modifyParameters,
-		}
-		if len(c.Fields) == 1 {
-			slack.PostMessage(c.Channel, "No message detected")
-		} else {
-			k := functions.GetKeys(controllerActions)
-			subcommand := strings.ToLower(c.Fields[1])
-			if functions.Contains(k, subcommand) {
-				f := controllerActions[subcommand]
-				f(c)
-			} else {
-				b.sendCompletion(c)
-			}
-		}
+		b.runCommand(b.commands(), c)
 	} else {
-		slack.PostMessage(c.Channel, "The "+b.name+" is disabled")
+		b.messenger.PostMessage(c.Channel, "The "+b.name+" is disabled")
 	}
 }
 
-func (b *openAIBot) sendCompletion(c slack.CommandInfo) {
-	prompt := strings.Join(c.Fields[1:], " ")
+func (b *openAIBot) sendCompletion(c slack.CommandInfo, a args) {
+	prompt := a.word("prompt")
 	req := gogpt.CompletionRequest{
-		Model:     b.model,
-		MaxTokens: b.maxTokens,
-		Prompt:    prompt,
+		Model:            b.options.Model,
+		MaxTokens:        b.options.MaxTokens,
+		Prompt:           prompt,
+		Temperature:      b.options.Temperature,
+		TopP:             b.options.TopP,
+		FrequencyPenalty: b.options.FrequencyPenalty,
+		PresencePenalty:  b.options.PresencePenalty,
 	}
 
-	deadline := time.Now().Add(b.defaultTimeout)
+	deadline := time.Now().Add(b.options.Timeout)
 	cont, cancel := context.WithDeadline(b.gptContext, deadline)
 	defer cancel()
 
 	resp, err := b.gptClient.CreateCompletion(cont, req)
 	if err != nil {
 		go b.logger.WithField("prompt", prompt).WithError(err).Warn("Could not find response for the prompt")
-		slack.PostMessage(c.Channel, "Could not find response for the prompt. "+err.Error())
+		b.messenger.PostMessage(c.Channel, "Could not find response for the prompt. "+err.Error())
 		return
 	}
 
 	m := resp.Choices[0].Text
-	go slack.PostMessage(c.Channel, m)
+	go b.messenger.PostMessage(c.Channel, m)
 	b.logger.WithField("prompt", prompt).Info(m)
 }
 
-func (b *openAIBot) modifyParameters(c slack.CommandInfo) {
+func (b *openAIBot) modifyParameters(c slack.CommandInfo, a args) {
 
 }
@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/vishhvaan/lab-bot/files"
-	"github.com/vishhvaan/lab-bot/functions"
 	"github.com/vishhvaan/lab-bot/slack"
 )
 
@@ -16,16 +16,21 @@ const paperFolder = "papers"
 
 type paperUploaderJob struct {
 	labJob
+	folder         string
 	downloadFolder string
 }
 
 func (pu *paperUploaderJob) init() {
 	pu.labJob.init()
 
-	filepath, err := files.CreateFolder(files.FindExeDir(), paperFolder)
+	base := files.FindExeDir()
+	if path.IsAbs(pu.folder) {
+		base = ""
+	}
+	filepath, err := files.CreateFolder(base, pu.folder)
 	if err != nil {
 		message := "Cannot create paper download folder"
-		slack.Message(message)
+		pu.messenger.Message(message)
 		pu.logger.Error(message)
 		pu.active = false
 	} else {
@@ -33,50 +38,43 @@ func (pu *paperUploaderJob) init() {
 	}
 }
 
+func (pu *paperUploaderJob) commands() commandSet {
+	return commandSet{
+		{args: []argSpec{word("url")}, desc: "Downloads the paper at the URL and uploads it", run: pu.paperDOIUploader},
+		{name: "pmid", args: []argSpec{word("pmid")}, desc: "Downloads the paper with the PubMed ID and uploads it", run: pu.paperPMIDUploader},
+	}
+}
+
 func (pu *paperUploaderJob) commandProcessor(c slack.CommandInfo) {
 	if pu.active {
-		controllerActions := map[string]action{
-			"pmid": pu.paperPMIDUploader,
-		}
-		if len(c.Fields) == 1 {
-			pu.errorMsg(c.Fields, c.Channel, "Include a URL or PMID with your request")
-		} else {
-			k := functions.GetKeys(controllerActions)
-			subcommand := strings.ToLower(c.Fields[1])
-			if functions.Contains(k, subcommand) {
-				f := controllerActions[subcommand]
-				f(c)
-			} else {
-				pu.paperDOIUploader(c)
-			}
-		}
+		pu.runCommand(pu.commands(), c)
 	} else {
-		slack.PostMessage(c.Channel, "The "+pu.name+" is disabled")
+		pu.messenger.PostMessage(c.Channel, "The "+pu.name+" is disabled")
 	}
 }
 
 func (pu *paperUploaderJob) errorMsg(fields []string, channel string, message string) {
 	go pu.logger.WithField("fields", fields).Warn(message)
-	slack.PostMessage(channel, message)
+	pu.messenger.PostMessage(channel, message)
 }
 
-func (pu *paperUploaderJob) paperDOIUploader(c slack.CommandInfo) {
-	paperURL := c.Fields[1]
-	paperURL = paperURL[1 : len(paperURL)-1]
+func (pu *paperUploaderJob) paperDOIUploader(c slack.CommandInfo, a args) {
+	paperURL := strings.TrimSuffix(strings.TrimPrefix(a.word("url"), "<"), ">")
 	url, err := url.ParseRequestURI(paperURL)
 
 	if err != nil {
 		pu.errorMsg(c.Fields, c.Channel, "Invalid URL")
 	} else {
 		command := fmt.Sprintf("scidownl download --doi \"%s\" --out %s", url.String(), pu.downloadFolder)
-		output, err := slack.CommandStreamer(command, "err", c.Channel, outputTimeout)
+		output, err := slack.CommandStreamer(pu.messenger, command, "err", c.Channel, outputTimeout)
 		if err == nil {
 			lastLine := output[len(output)-1]
 			if strings.Contains(lastLine, "Successful") {
 				i := strings.Index(lastLine, ": ")
 				pdfPath := lastLine[i+2:]
 				pu.logger.WithField("path", pdfPath).Info("Uploading File")
-				slack.UploadFile(c.Channel, pdfPath, "")
+				err = pu.messenger.UploadFile(c.Channel, pdfPath, "")
+				pu.audit(c, "upload", path.Base(pdfPath), err)
 				pu.logger.WithField("path", pdfPath).Info("Deleting File")
 				files.DeleteFile(pdfPath)
 			} else {
@@ -91,6 +89,6 @@ func (pu *paperUploaderJob) paperDOIUploader(c slack.CommandInfo) {
 
 }
 
-func (pu *paperUploaderJob) paperPMIDUploader(c slack.CommandInfo) {
+func (pu *paperUploaderJob) paperPMIDUploader(c slack.CommandInfo, a args) {
 
 }
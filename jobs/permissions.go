@@ -0,0 +1,281 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/config"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+// Permissions limit who can run a job or some of its subcommands. Jobs
+// declare the roles their subcommands need, the jobs config can change
+// them, and controllers can be kept to an allow list of members and roles.
+// Members are matched by their Slack user ID, and admins can run anything.
+
+const (
+	adminRole    = "admin"
+	postdocRole  = "postdoc"
+	techRole     = "tech"
+	everyoneRole = "everyone"
+)
+
+type restrictedJob interface {
+	requiredRoles() map[string][]string
+}
+
+type access struct {
+	allow           []string
+	subcommands     map[string][]string
+	approval        []string
+	approvalTimeout time.Duration
+	commands        func() commandSet
+}
+
+func isAdmin(user string) bool {
+	member, ok := config.MemberByID(user)
+	return ok && member.HasRole(adminRole)
+}
+
+func newAccess(j job) *access {
+	a := &access{subcommands: make(map[string][]string)}
+	if cj, ok := j.(commandJob); ok {
+		a.commands = cj.commands
+	}
+	if rj, ok := j.(restrictedJob); ok {
+		for subcommand, roles := range rj.requiredRoles() {
+			a.subcommands[subcommand] = roles
+		}
+	}
+	return a
+}
+
+// restrict applies the allow list, permissions and approvals of the config
+// to a job, on top of the roles the job asks for itself.
+func (jh *JobHandler) restrict(keyword string, allow []string, permissions map[string][]string, approval config.ApprovalConfig) error {
+	a := jh.access[keywordKey(keyword)]
+	a.allow = allow
+	for subcommand, roles := range permissions {
+		key := subcommandKey(subcommand)
+		if key == "" {
+			return errors.New("permissions need a subcommand")
+		}
+		if len(roles) == 0 {
+			return errors.New("permissions of \"" + subcommand + "\" need roles, or " + everyoneRole)
+		}
+		a.subcommands[key] = roles
+	}
+
+	for _, subcommand := range approval.Commands {
+		key := subcommandKey(subcommand)
+		if key == "" {
+			return errors.New("approval commands can't be empty")
+		}
+		a.approval = append(a.approval, key)
+	}
+	if approval.Timeout < 0 {
+		return errors.New("approval timeout can't be negative")
+	}
+	a.approvalTimeout = approval.Timeout
+	if a.approvalTimeout == 0 {
+		a.approvalTimeout = defaultApprovalTimeout
+	}
+	return nil
+}
+
+func subcommandKey(subcommand string) string {
+	return strings.ToLower(strings.Join(strings.Fields(subcommand), " "))
+}
+
+// check tells why the user can't run the command, or nothing when they can.
+// Scheduled commands are always allowed, they were checked when they were
+// scheduled.
+func (a *access) check(c slack.CommandInfo) (denied string) {
+	if a == nil || c.Scheduled {
+		return ""
+	}
+	name, member, isMember := config.FindMember(c.User)
+	if isMember && member.HasRole(adminRole) {
+		return ""
+	}
+	keyword := strings.ToLower(c.Fields[0])
+
+	if len(a.allow) > 0 && !allowed(a.allow, name, member, isMember) {
+		return "Sorry, you aren't on the allow list of `" + keyword + "`."
+	}
+
+	commands := a.commandWords(c)
+	subcommands := make([]string, 0, len(a.subcommands))
+	for subcommand := range a.subcommands {
+		subcommands = append(subcommands, subcommand)
+	}
+	sort.Strings(subcommands)
+	for _, subcommand := range subcommands {
+		roles := a.subcommands[subcommand]
+		if !matchesAny(commands, subcommand) || allowed(roles, name, member, isMember) {
+			continue
+		}
+		denied = "Sorry, `" + strings.TrimSpace(keyword+" "+subcommand) + "` needs the " + orList(roles) + " role"
+		if !isMember {
+			denied += ", and you aren't in the members list"
+		}
+		return denied + "."
+	}
+	return ""
+}
+
+// needsApproval tells which of the job's approval commands the command is,
+// if any.
+func (a *access) needsApproval(c slack.CommandInfo) (subcommand string) {
+	if a == nil || c.Scheduled {
+		return ""
+	}
+	commands := a.commandWords(c)
+	for _, subcommand := range a.approval {
+		if matchesAny(commands, subcommand) {
+			return subcommand
+		}
+	}
+	return ""
+}
+
+// switchingJob is a job whose commands switch other controllers, like a
+// group or the scenes. Those commands are also checked as the power commands
+// they run on each controller, so a group or scene can't switch a device the
+// user couldn't, or skip the approval it needs.
+type switchingJob interface {
+	switches(c slack.CommandInfo) []slack.CommandInfo
+}
+
+// switchCommand is the command that switches a controller the way a group or
+// scene does it for the user who asked.
+func switchCommand(cj *controllerJob, c slack.CommandInfo, power string) slack.CommandInfo {
+	c.Fields = append([]string{cj.keyword}, strings.Fields(power)...)
+	c.Text = ""
+	return c
+}
+
+// gate is an access and the command it is checked with.
+type gate struct {
+	access  *access
+	command slack.CommandInfo
+}
+
+func (jh *JobHandler) gates(job string, c slack.CommandInfo) []gate {
+	gates := []gate{{jh.access[job], c}}
+	if sj, ok := jh.jobs[job].(switchingJob); ok {
+		for _, sc := range sj.switches(c) {
+			gates = append(gates, gate{jh.access[keywordKey(sc.Fields[0])], sc})
+		}
+	}
+	return gates
+}
+
+// check tells why the user can't run the command of a job, or nothing when
+// they can.
+func (jh *JobHandler) check(job string, c slack.CommandInfo) (denied string) {
+	for _, g := range jh.gates(job, c) {
+		if denied = g.access.check(g.command); denied != "" {
+			return denied
+		}
+	}
+	return ""
+}
+
+// needsApproval tells which approval command the command of a job is, and
+// how long a request for it stays open.
+func (jh *JobHandler) needsApproval(job string, c slack.CommandInfo) (subcommand string, timeout time.Duration) {
+	for _, g := range jh.gates(job, c) {
+		if subcommand = g.access.needsApproval(g.command); subcommand != "" {
+			return subcommand, g.access.approvalTimeout
+		}
+	}
+	return "", 0
+}
+
+// commandWords gives the words permissions are decided on: the name of the
+// command the job resolves it to and the flags it was given, so the check is
+// on what runs rather than on what was typed. A command that sets a schedule
+// is also checked as the command the schedule runs, so scheduling `on` needs
+// what `on` does. Commands the job doesn't know are checked on the words as
+// typed, though they don't run.
+func (a *access) commandWords(c slack.CommandInfo) (commands [][]string) {
+	if a.commands != nil {
+		if cmd, args, _ := a.commands().parse(strings.ToLower(c.Fields[0]), c); cmd != nil {
+			words := strings.Fields(cmd.name)
+			for _, f := range cmd.flags {
+				if args.flag(f) {
+					words = append(words, f)
+				}
+			}
+			commands = append(commands, words)
+			if cmd.schedules != "" {
+				commands = append(commands, strings.Fields(cmd.schedules))
+			}
+			return commands
+		}
+	}
+	var words []string
+	for _, f := range c.Fields[1:] {
+		words = append(words, strings.ToLower(f))
+	}
+	return append(commands, words)
+}
+
+func matchesAny(commands [][]string, subcommand string) bool {
+	for _, words := range commands {
+		if matchesSubcommand(words, strings.Fields(subcommand)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed tells whether a member is named in the list or has one of its
+// roles.
+func allowed(list []string, name string, member config.Member, isMember bool) bool {
+	for _, entry := range list {
+		if entry == everyoneRole || isMember && (entry == name || member.HasRole(entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+func orList(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+// matchesSubcommand tells whether the command starts with the subcommand
+// and has the rest of its words in order, so "schedule remove" matches
+// "schedule on remove". An empty subcommand matches the whole job.
+func matchesSubcommand(words []string, subcommand []string) bool {
+	if len(subcommand) == 0 {
+		return true
+	}
+	if len(words) == 0 || words[0] != subcommand[0] {
+		return false
+	}
+	i := 0
+	for _, w := range words {
+		if i < len(subcommand) && w == subcommand[i] {
+			i++
+		}
+	}
+	return i == len(subcommand)
+}
+
+func (jh *JobHandler) denied(c slack.CommandInfo, message string) {
+	go jh.logger.WithFields(log.Fields{
+		"user":    c.User,
+		"command": strings.Join(c.Fields, " "),
+	}).Warn("Denied a command")
+	jh.messenger.PostMessage(c.Channel, message)
+}
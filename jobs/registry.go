@@ -0,0 +1,372 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vishhvaan/lab-bot/config"
+	"github.com/vishhvaan/lab-bot/drivers"
+	"github.com/vishhvaan/lab-bot/scheduling"
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+type jobType struct {
+	name    string
+	desc    string
+	jobtype string
+	job     string
+	create  func(jc config.JobConfig, lj labJob) (job, error)
+}
+
+var jobTypes = map[string]jobType{
+	"paper": {
+		name:    "Paper Uploader",
+		desc:    "Uploads papers downloaded from the scidownl utility",
+		jobtype: "uploader",
+		job:     "paperUploader",
+		create:  newPaperUploaderJob,
+	},
+	"openai": {
+		name:    "OpenAI Bot",
+		desc:    "Passes queries to the OpenAI API and returns top completion",
+		jobtype: "bot",
+		job:     "openAIBot",
+		create:  newOpenAIBot,
+	},
+	"birthday": {
+		name:    "Birthday Bot",
+		desc:    "Monitors, alerts, and records member birthdays",
+		jobtype: "bot",
+		job:     "birthdayBot",
+		create:  newBirthdayJob,
+	},
+	"labmeeting": {
+		name:    "Lab Meeting",
+		desc:    "Keeps track of lab meeting groups and presenters",
+		jobtype: "bot",
+		job:     "labMeeting",
+		create:  newLabMeetingJob,
+	},
+	"holidays": {
+		name:    "Holiday Calendar",
+		desc:    "Keeps the days the lab is closed, when schedules don't run",
+		jobtype: "bot",
+		job:     "holidays",
+		create:  newHolidayJob,
+	},
+}
+
+// keywordKey is how a keyword appears in a Slack message: lowercase, with
+// &, < and > escaped.
+func keywordKey(keyword string) string {
+	return slack.EscapeText(strings.ToLower(keyword))
+}
+
+func buildJob(jc config.JobConfig, messenger slack.Messenger, jobLogger *log.Entry, holidays *scheduling.Calendar) (j job, err error) {
+	jt, ok := jobTypes[jc.Type]
+	if !ok {
+		return nil, errors.New("unknown job type \"" + jc.Type + "\"")
+	}
+	if jc.Keyword == "" {
+		return nil, errors.New("job needs a keyword")
+	}
+
+	lj := labJob{
+		name:    jt.name,
+		keyword: jc.Keyword,
+		active:  true,
+		desc:    jt.desc,
+		logger: jobLogger.WithFields(log.Fields{
+			"jobtype": jt.jobtype,
+			"job":     jt.job,
+		}),
+		messenger: messenger,
+		holidays:  holidays,
+	}
+	if jc.Name != "" {
+		lj.name = jc.Name
+	}
+	if jc.Desc != "" {
+		lj.desc = jc.Desc
+	}
+
+	return jt.create(jc, lj)
+}
+
+func newPaperUploaderJob(jc config.JobConfig, lj labJob) (job, error) {
+	options := struct {
+		Folder string `yaml:"folder"`
+	}{
+		Folder: paperFolder,
+	}
+	if err := jc.DecodeOptions(&options); err != nil {
+		return nil, err
+	}
+
+	return &paperUploaderJob{
+		labJob: lj,
+		folder: options.Folder,
+	}, nil
+}
+
+func newOpenAIBot(jc config.JobConfig, lj labJob) (job, error) {
+	options := defaultOpenAIOptions
+	if err := jc.DecodeOptions(&options); err != nil {
+		return nil, err
+	}
+
+	return &openAIBot{
+		labJob:  lj,
+		options: options,
+	}, nil
+}
+
+func newBirthdayJob(jc config.JobConfig, lj labJob) (job, error) {
+	if jc.Channel == "" {
+		return nil, errors.New("birthday job needs a channel for the birthday messages")
+	}
+	cronExp := jc.Cron
+	if cronExp == "" {
+		cronExp = defaultBirthdayCron
+	}
+	if _, err := cron.ParseStandard(cronExp); err != nil {
+		return nil, fmt.Errorf("invalid cron %q: %w", cronExp, err)
+	}
+
+	return &birthdayJob{
+		labJob: lj,
+		scheduling: scheduling.BirthdaySchedule{
+			BirthdayMessageChannel: jc.Channel,
+			CronExp:                cronExp,
+			Logger:                 lj.logger.WithField("task", "scheduling"),
+			Messenger:              lj.messenger,
+			Holidays:               lj.holidays,
+			RespectHolidays:        jc.RespectsHolidays(),
+		},
+	}, nil
+}
+
+func newLabMeetingJob(jc config.JobConfig, lj labJob) (job, error) {
+	return &labMeetingJob{
+		labJob: lj,
+	}, nil
+}
+
+func newHolidayJob(jc config.JobConfig, lj labJob) (job, error) {
+	options := struct {
+		File  string   `yaml:"file"`
+		Dates []string `yaml:"dates"`
+	}{}
+	if err := jc.DecodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if err := lj.holidays.LoadFixed(options.File, options.Dates); err != nil {
+		return nil, err
+	}
+
+	return &holidayJob{
+		labJob: lj,
+	}, nil
+}
+
+func buildController(cc config.ControllerConfig, messenger slack.Messenger, jobLogger *log.Entry, holidays *scheduling.Calendar) (cj *controllerJob, err error) {
+	if cc.Keyword == "" {
+		return nil, errors.New("controller needs a keyword")
+	}
+	if cc.Machine == "" {
+		return nil, errors.New("controller needs a machine name")
+	}
+	if cc.Driver == "" {
+		return nil, errors.New("controller needs a driver, one of " + strings.Join(drivers.Names(), ", "))
+	}
+
+	device, err := drivers.New(cc.Driver, cc.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	name := cc.Name
+	if name == "" {
+		name = strings.Title(cc.Machine) + " Controller"
+	}
+	logger := jobLogger.WithFields(log.Fields{
+		"jobtype": "controller",
+		"job":     cc.Keyword,
+	})
+
+	cj = &controllerJob{
+		labJob: labJob{
+			name:      name,
+			keyword:   cc.Keyword,
+			active:    true,
+			desc:      "Turns the " + cc.Machine + " on and off",
+			logger:    logger,
+			messenger: messenger,
+			holidays:  holidays,
+		},
+		machineName: cc.Machine,
+		powerState:  "off",
+		device:      device,
+		customInit:  device.Init,
+		customOn:    device.On,
+		customOff:   device.Off,
+		scheduling: scheduling.ControllerSchedule{
+			Logger:    logger.WithField("task", "scheduling"),
+			Messenger: messenger,
+			Holidays:  holidays,
+		},
+	}
+	if sr, ok := device.(drivers.StatusReader); ok {
+		cj.customStatus = sr.Status
+		// devices that report changes themselves don't need polling
+		if _, notifies := device.(drivers.StateNotifier); !notifies {
+			cj.pollInterval = defaultPollInterval
+		}
+	}
+	if cc.MaxOn < 0 || cc.MaxOnWarning < 0 {
+		return nil, errors.New("max_on and max_on_warning can't be negative")
+	}
+	cj.autoOff.maxOn = cc.MaxOn
+	cj.autoOff.warning = cc.MaxOnWarning
+	if cj.autoOff.warning == 0 {
+		cj.autoOff.warning = defaultMaxOnWarning
+	}
+	if cj.autoOff.warning > cc.MaxOn/2 {
+		cj.autoOff.warning = cc.MaxOn / 2
+	}
+	if cc.Poll != nil {
+		if cj.customStatus == nil && *cc.Poll > 0 {
+			return nil, errors.New("the " + cc.Driver + " driver can't read the device state to poll it")
+		}
+		cj.pollInterval = *cc.Poll
+	}
+	return cj, nil
+}
+
+func buildGroup(gc config.GroupConfig, members []*controllerJob, messenger slack.Messenger, jobLogger *log.Entry, holidays *scheduling.Calendar) (cj *controllerJob, err error) {
+	if gc.Keyword == "" {
+		return nil, errors.New("group needs a keyword")
+	}
+	if len(members) == 0 {
+		return nil, errors.New("group needs controllers")
+	}
+
+	name := gc.Name
+	if name == "" {
+		name = strings.Title(gc.Keyword) + " Group"
+	}
+	var machines []string
+	for _, member := range members {
+		machines = append(machines, member.machineName)
+	}
+	logger := jobLogger.WithFields(log.Fields{
+		"jobtype": "group",
+		"job":     gc.Keyword,
+	})
+
+	return &controllerJob{
+		labJob: labJob{
+			name:      name,
+			keyword:   gc.Keyword,
+			active:    true,
+			desc:      "Turns the " + strings.Join(machines, ", ") + " on and off together",
+			logger:    logger,
+			messenger: messenger,
+			holidays:  holidays,
+		},
+		machineName: gc.Keyword + " group",
+		powerState:  "off",
+		customInit:  func() error { return nil },
+		members:     members,
+		scheduling: scheduling.ControllerSchedule{
+			Logger:    logger.WithField("task", "scheduling"),
+			Messenger: messenger,
+			Holidays:  holidays,
+		},
+	}, nil
+}
+
+// controllers finds the controllers with the given keywords for a group or
+// a scene. Controllers disabled in the config are left out.
+func (jh *JobHandler) controllers(keywords []string, configs []config.ControllerConfig) (found []*controllerJob, err error) {
+	for _, keyword := range keywords {
+		cj, err := jh.controller(keyword, configs)
+		if err != nil {
+			return nil, err
+		}
+		if cj != nil {
+			found = append(found, cj)
+		}
+	}
+	return found, nil
+}
+
+// controller finds the controller with a keyword, or nil if it is disabled
+// in the config.
+func (jh *JobHandler) controller(keyword string, configs []config.ControllerConfig) (*controllerJob, error) {
+	key, ok := jh.keywords[keywordKey(keyword)]
+	cj, isController := jh.jobs[key].(*controllerJob)
+	switch {
+	case ok && isController && cj.members == nil:
+		return cj, nil
+	case ok && isController:
+		return nil, errors.New("\"" + keyword + "\" is a group, groups can't be used here")
+	}
+
+	for _, cc := range configs {
+		if strings.EqualFold(cc.Keyword, keyword) && !cc.IsEnabled() {
+			jh.logger.WithField("keyword", keyword).Info("Leaving out disabled controller")
+			return nil, nil
+		}
+	}
+	return nil, errors.New("there is no controller \"" + keyword + "\"")
+}
+
+func buildScenes(scs []config.SceneConfig, jh *JobHandler, configs []config.ControllerConfig) (sj *sceneJob, err error) {
+	sj = &sceneJob{
+		labJob: labJob{
+			name:      "Scenes",
+			keyword:   "scene",
+			active:    true,
+			desc:      "Sets several controllers to given states at once",
+			logger:    jh.logger.WithFields(log.Fields{"jobtype": "bot", "job": "scenes"}),
+			messenger: jh.messenger,
+		},
+	}
+	for i, sc := range scs {
+		s := scene{name: sc.Name, desc: sc.Desc}
+		switch {
+		case sc.Name == "" || strings.ContainsAny(sc.Name, " \t"):
+			err = errors.New("scene needs a name of one word")
+		case strings.EqualFold(sc.Name, "list"):
+			err = errors.New("a scene can't be called list")
+		case len(sc.States) == 0:
+			err = errors.New("scene needs states")
+		case sj.find(sc.Name) != nil:
+			err = errors.New("scene name is used twice")
+		}
+		for _, state := range sc.States {
+			if err != nil {
+				break
+			}
+			if state.State != "on" && state.State != "off" {
+				err = errors.New("the state of " + state.Controller + " should be on or off")
+				break
+			}
+			var cjs []*controllerJob
+			cjs, err = jh.controllers([]string{state.Controller}, configs)
+			for _, cj := range cjs {
+				s.states = append(s.states, sceneState{controller: cj, state: state.State})
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scene %d (%s): %w", i+1, sc.Name, err)
+		}
+		sj.scenes = append(sj.scenes, s)
+	}
+	return sj, nil
+}
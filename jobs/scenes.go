@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"strings"
+
+	"github.com/vishhvaan/lab-bot/slack"
+)
+
+type sceneJob struct {
+	labJob
+	scenes []scene
+}
+
+type scene struct {
+	name   string
+	desc   string
+	states []sceneState
+}
+
+type sceneState struct {
+	controller *controllerJob
+	state      string
+}
+
+func (sj *sceneJob) init() {
+	sj.labJob.init()
+	sj.logger.Info(sj.name + " loaded")
+}
+
+func (sj *sceneJob) find(name string) *scene {
+	for i := range sj.scenes {
+		if strings.EqualFold(sj.scenes[i].name, name) {
+			return &sj.scenes[i]
+		}
+	}
+	return nil
+}
+
+func (sj *sceneJob) commands() commandSet {
+	return commandSet{
+		{args: []argSpec{word("scene").opt()}, desc: "Sets a scene, or lists them when none is given", run: sj.set},
+		{name: "list", desc: "Lists the scenes and what they switch", run: sj.list},
+	}
+}
+
+func (sj *sceneJob) commandProcessor(c slack.CommandInfo) {
+	if !sj.active {
+		sj.messenger.PostMessage(c.Channel, "The "+sj.name+" job is disabled")
+		return
+	}
+	sj.runCommand(sj.commands(), c)
+}
+
+func (sj *sceneJob) set(c slack.CommandInfo, a args) {
+	if !a.has("scene") {
+		sj.list(c, a)
+		return
+	}
+	s := sj.find(a.word("scene"))
+	if s == nil {
+		message := "There is no scene called " + a.word("scene") + ", `scene list` shows them"
+		go sj.logger.WithField("fields", c.Fields).Warn(message)
+		sj.messenger.PostMessage(c.Channel, message)
+		return
+	}
+	sj.apply(c, s)
+}
+
+// switches gives the power commands setting a scene runs.
+func (sj *sceneJob) switches(c slack.CommandInfo) (commands []slack.CommandInfo) {
+	cmd, a, _ := sj.commands().parse(strings.ToLower(c.Fields[0]), c)
+	if cmd == nil || cmd.name != "" || !a.has("scene") {
+		return nil
+	}
+	if s := sj.find(a.word("scene")); s != nil {
+		for _, state := range s.states {
+			commands = append(commands, switchCommand(state.controller, c, state.state))
+		}
+	}
+	return commands
+}
+
+func (sj *sceneJob) list(c slack.CommandInfo, a args) {
+	var lines []string
+	for _, s := range sj.scenes {
+		var states []string
+		for _, state := range s.states {
+			states = append(states, state.controller.keyword+" "+state.state)
+		}
+		line := "*" + s.name + "*"
+		if s.desc != "" {
+			line += " (" + s.desc + ")"
+		}
+		lines = append(lines, line+": "+strings.Join(states, ", "))
+	}
+	sj.messenger.PostMessage(c.Channel, strings.Join(lines, "\n"))
+}
+
+// apply switches the controllers of the scene in order and reports on all
+// of them.
+func (sj *sceneJob) apply(c slack.CommandInfo, s *scene) {
+	var results []memberResult
+	for _, state := range s.states {
+		results = append(results, state.controller.switchPower(c, state.state, false))
+	}
+	lines, failed := describeResults(results)
+
+	if failed == 0 {
+		message := "Set the " + s.name + " scene"
+		go sj.logger.Info(message)
+		if c.TimeStamp != "" {
+			sj.messenger.React(c.TimeStamp, c.Channel, "ok_hand")
+		}
+		sj.messenger.Message(message + lines)
+		return
+	}
+	message := "Couldn't set all of the " + s.name + " scene, " + devicesFailed(failed, len(results))
+	go sj.logger.WithField("failed", failed).Error(message)
+	sj.messenger.Message(message + lines)
+}
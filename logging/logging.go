@@ -13,6 +13,12 @@ const logFolder = "logs"
 const logExt = ".log"
 const logLevel = log.InfoLevel
 
+var console io.Writer = os.Stdout
+
+func SetConsole(w io.Writer) {
+	console = w
+}
+
 func Setup() {
 	log.SetLevel(logLevel)
 
@@ -40,7 +46,7 @@ func CreateNewLogger(prefix string, filename string) *log.Entry {
 
 	logPath := CreateLogFolder()
 	logFile := CreateLogFile(logPath, filename)
-	mw := io.MultiWriter(os.Stdout, logFile)
+	mw := io.MultiWriter(console, logFile)
 	logger.SetOutput(mw)
 
 	return logger.WithField("logger", prefix)
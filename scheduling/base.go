@@ -1,18 +1,27 @@
 package scheduling
 
 import (
-	log "github.com/sirupsen/logrus"
+	"time"
 
 	"github.com/go-co-op/gocron"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+
 	"github.com/vishhvaan/lab-bot/logging"
 	"github.com/vishhvaan/lab-bot/slack"
 )
 
 type scheduleRecord struct {
-	ID      string
-	Name    string
-	CronExp string
-	Command slack.CommandInfo
+	ID             string
+	Name           string
+	Power          string `json:",omitempty"`
+	Label          string `json:",omitempty"`
+	CronExp        string
+	Command        slack.CommandInfo
+	IgnoreHolidays bool       `json:",omitempty"`
+	Paused         bool       `json:",omitempty"`
+	PausedUntil    *time.Time `json:",omitempty"`
+	SkipAt         *time.Time `json:",omitempty"`
 }
 
 type Schedule struct {
@@ -81,3 +90,17 @@ func (st *ScheduleTracker) Reciever() {
 		}
 	}
 }
+
+const maxCronRuns = 1000
+
+func cronRuns(cronExp string, from time.Time, to time.Time) (runs []time.Time, err error) {
+	s, err := cron.ParseStandard(cronExp)
+	if err != nil {
+		return nil, err
+	}
+
+	for next := s.Next(from); !next.After(to) && len(runs) < maxCronRuns; next = s.Next(next) {
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
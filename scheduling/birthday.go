@@ -12,6 +12,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/functions"
 	"github.com/vishhvaan/lab-bot/slack"
 )
 
@@ -21,6 +22,9 @@ type BirthdaySchedule struct {
 	CronExp                string
 	dbPath                 []string
 	Logger                 *log.Entry
+	Messenger              slack.Messenger
+	Holidays               *Calendar
+	RespectHolidays        bool
 	sched                  map[string]*Schedule
 }
 
@@ -44,15 +48,34 @@ func (bs *BirthdaySchedule) Init(dbPath []string, logger *log.Entry) {
 	}
 
 	bs.scheduler.StartAsync()
-	slack.Message("Scheduling daily birthday messages " + scheduledText)
+	bs.Messenger.Message("Scheduling daily birthday messages " + scheduledText)
 	bs.Logger.Info("daily birthday messages " + scheduledText)
 }
 
+// Stop stops the daily birthday check, so Init can start it again.
+func (bs *BirthdaySchedule) Stop() {
+	if bs.scheduler != nil {
+		bs.scheduler.Stop()
+	}
+}
+
+// DueRuns lists the times the daily birthday check would have run in (from, to].
+func (bs *BirthdaySchedule) DueRuns(from time.Time, to time.Time) (runs []time.Time, err error) {
+	return cronRuns(bs.CronExp, from, to)
+}
+
+func (bs *BirthdaySchedule) Congratulate() {
+	bs.congratulate(bs.BirthdayMessageChannel)
+}
+
 func (bs *BirthdaySchedule) congratulate(channel string) {
+	if bs.RespectHolidays && bs.Holidays.Skip("birthday", "the birthday post", functions.Now()) {
+		return
+	}
 	upcomingBirthdays, err := bs.readUpcomingBirthdays(false)
 	if err != nil {
 		go bs.Logger.WithError(err).Warn("cannot run daily birthday checks")
-		slack.Message("Cannot run daily birthday checks.")
+		bs.Messenger.Message("Cannot run daily birthday checks.")
 		return
 	}
 
@@ -64,22 +87,12 @@ func (bs *BirthdaySchedule) congratulate(channel string) {
 	if len(todayBDs) > 0 {
 		birthdayMessage := "Happy Birthday " + strings.Join(todayBDs, ", ") + "! :tada:"
 		bs.Logger.Info("birthdays found for today")
-		slack.SendMessage(channel, birthdayMessage)
+		bs.Messenger.SendMessage(channel, birthdayMessage)
 	}
 	bs.Logger.Info("no birthdays found for today")
 }
 
-func (bs *BirthdaySchedule) UpcomingBirthdays(c slack.CommandInfo) {
-	var force bool
-	if len(c.Fields) > 2 {
-		if c.Fields[2] == "force" {
-			force = true
-		} else {
-			slack.SendMessage(c.Channel, "only the force flag is supported")
-			return
-		}
-	}
-
+func (bs *BirthdaySchedule) UpcomingBirthdays(c slack.CommandInfo, force bool) {
 	upcomingBirthdays, err := bs.readUpcomingBirthdays(force)
 	if err != nil {
 		bs.errorMsg(c, err, "cannot read upcoming birthdays")
@@ -91,7 +104,7 @@ func (bs *BirthdaySchedule) UpcomingBirthdays(c slack.CommandInfo) {
 	if err != nil {
 		bs.errorMsg(c, err, "cannot format upcoming birthdays")
 	}
-	slack.PostMessage(c.Channel, message)
+	bs.Messenger.PostMessage(c.Channel, message)
 
 }
 
@@ -115,7 +128,7 @@ func (bs *BirthdaySchedule) readUpcomingBirthdays(force bool) (upcomingBirthdays
 		return nil, err
 	}
 
-	todayLocal := startOfLocalDay(time.Now())
+	todayLocal := startOfLocalDay(functions.Now())
 	updatedLocal := startOfLocalDay(lastUpdated)
 	if updatedLocal.Equal(todayLocal) && !force {
 		uB, err := db.ReadValue(append(bs.dbPath, "upcoming"), "birthdays")
@@ -144,7 +157,7 @@ func (bs *BirthdaySchedule) generateUpcomingBirthdays() (upcomingBirthdays map[s
 	upcomingBirthdays["nextWeekBDs"] = make(map[string]time.Time)
 	upcomingBirthdays["nextMonthBDs"] = make(map[string]time.Time)
 
-	now := time.Now()
+	now := functions.Now()
 
 	today := startOfLocalDay(now)
 	tomorrow := today.AddDate(0, 0, 1)
@@ -219,7 +232,7 @@ func (bs *BirthdaySchedule) formatUpcomingBirthdays(upcomingBirthdays map[string
 		}
 		var list []bdentry
 		for id, d := range users {
-			name := slack.GetUserName(id)
+			name := bs.Messenger.GetUserName(id)
 			if name == "" { // fallback to mention if display-name missing
 				name = "<@" + id + ">"
 			}
@@ -252,7 +265,7 @@ func (bs *BirthdaySchedule) formatUpcomingBirthdays(upcomingBirthdays map[string
 
 func (bs *BirthdaySchedule) errorMsg(c slack.CommandInfo, err error, message string) {
 	go bs.Logger.WithField("fields", c.Fields).WithError(err).Warn(message)
-	slack.PostMessage(c.Channel, message)
+	bs.Messenger.PostMessage(c.Channel, message)
 }
 
 func startOfLocalDay(t time.Time) time.Time {
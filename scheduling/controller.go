@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron"
@@ -17,94 +19,368 @@ import (
 	"github.com/vishhvaan/lab-bot/slack"
 )
 
+// ControllerSchedule runs the schedules of a controller. Locker is the lock
+// of the controller, held while a run is checked, since skipping one changes
+// the power message its commands also change.
 type ControllerSchedule struct {
 	Set                   bool
 	powerMessageChannel   string
 	powerMessageTimestamp string
+	powerMessageName      string
+	powerMessageStatus    string
+	powerMessageNoted     string
 	Logger                *log.Entry
+	Messenger             slack.Messenger
 	Sched                 map[string]*Schedule
 	DbPath                []string
+	Holidays              *Calendar
+	Locker                sync.Locker
+	mu                    sync.Mutex
 }
 
-func (cs *ControllerSchedule) ContSet(id string, cronSched string, command slack.CommandInfo, newSched bool) (err error) {
-	powerVal := command.Fields[2]
-	if cs.Sched[powerVal] != nil && cs.Sched[powerVal].scheduler != nil && cs.Sched[powerVal].scheduler.IsRunning() {
-		return errors.New("there exists a scheduled " + powerVal + " task")
-	} else {
-		_, err = cron.ParseStandard(cronSched)
+func (cs *ControllerSchedule) ContSet(id string, cronSched string, label string, command slack.CommandInfo, newSched bool) (err error) {
+	return cs.start(scheduleRecord{
+		ID:      id,
+		Name:    command.Fields[0] + " " + command.Fields[2],
+		Power:   command.Fields[2],
+		Label:   label,
+		CronExp: cronSched,
+		Command: command,
+	}, newSched)
+}
+
+// ContLoad starts a schedule read from the database.
+func (cs *ControllerSchedule) ContLoad(record scheduleRecord) error {
+	return cs.start(record, false)
+}
+
+func (cs *ControllerSchedule) start(record scheduleRecord, newSched bool) (err error) {
+	powerVal := record.Power
+	for _, schedule := range cs.running() {
+		if schedule.Power == powerVal && schedule.CronExp == record.CronExp {
+			return errors.New("there already is a scheduled " + powerVal + " task at that time, with the ID " + schedule.ID)
+		}
+		if record.Label != "" && strings.EqualFold(schedule.Label, record.Label) {
+			return errors.New("there already is a scheduled task called " + record.Label)
+		}
+	}
+
+	_, err = cron.ParseStandard(record.CronExp)
+	if err != nil {
+		return err
+	}
+
+	s := gocron.NewScheduler(time.Now().Local().Location())
+	sch := &Schedule{
+		scheduleRecord: record,
+		scheduler:      s,
+		logger:         cs.Logger.WithField("job", record.Name),
+	}
+
+	s.Cron(record.CronExp).Tag(powerVal).Do(func() {
+		cs.Locker.Lock()
+		skip := cs.skipRun(sch, functions.Now())
+		cs.Locker.Unlock()
+		if !skip {
+			slack.CommandChan <- scheduledCommand(record.Command)
+		}
+	})
+	s.StartAsync()
+
+	if newSched {
+		err = cs.writeSchedtoDB(record)
+		l := cs.Logger.WithFields(log.Fields{
+			"id":   record.ID,
+			"name": record.Name,
+		})
 		if err != nil {
-			return err
+			l.WithError(err).Error("Cannot add schedule to db")
+		} else {
+			l.Info("Added schedule to db")
 		}
+	}
+
+	if err == nil {
+		cs.Sched[record.ID] = sch
+		cs.Set = true
+	} else {
+		s.Stop()
+	}
+	return err
+}
+
+// Stop stops the schedulers of the schedules, which start again when they
+// are loaded from the database.
+func (cs *ControllerSchedule) Stop() {
+	for id, schedule := range cs.Sched {
+		schedule.scheduler.Stop()
+		delete(cs.Sched, id)
+	}
+	cs.Set = false
+}
 
-		s := gocron.NewScheduler(time.Now().Local().Location())
+// SkipRun tells whether the run of a schedule due at a time is skipped:
+// when the schedule is paused, when the run was asked to be skipped, or when
+// it falls on a holiday the schedule respects.
+func (cs *ControllerSchedule) SkipRun(id string, at time.Time) bool {
+	cs.Locker.Lock()
+	defer cs.Locker.Unlock()
+	schedule, ok := cs.Sched[id]
+	return ok && cs.skipRun(schedule, at)
+}
 
-		name := command.Fields[0] + " " + command.Fields[2]
-		s.Cron(cronSched).Tag(powerVal).Do(func(command slack.CommandInfo, id string, name string) {
-			slack.CommandChan <- slack.CommandInfo{
-				Fields:  []string{command.Fields[0], command.Fields[2]},
-				Channel: command.Channel,
-			}
-		}, command, id, name)
-		s.StartAsync()
-
-		record := scheduleRecord{
-			ID:      id,
-			Name:    name,
-			CronExp: cronSched,
-			Command: command,
+// skipRun is SkipRun with the Locker held.
+func (cs *ControllerSchedule) skipRun(schedule *Schedule, at time.Time) bool {
+	cs.mu.Lock()
+	var skip, changed bool
+	switch {
+	case schedule.pausedAt(at):
+		skip = true
+	case schedule.Paused:
+		// the pause is over
+		schedule.Paused, schedule.PausedUntil = false, nil
+		changed = true
+	}
+	if !skip && schedule.SkipAt != nil && !at.Before(schedule.SkipAt.Add(-skipMargin)) {
+		skip = at.Before(schedule.SkipAt.Add(skipMargin))
+		schedule.SkipAt = nil
+		changed = true
+	}
+	ignore := schedule.IgnoreHolidays
+	record := schedule.scheduleRecord
+	cs.mu.Unlock()
+
+	if changed {
+		if err := cs.updateSchedInDB(record); err != nil {
+			cs.Logger.WithError(err).WithField("id", record.ID).Error("Cannot update schedule in database")
 		}
+		cs.RefreshPowerMessage()
+	}
+	if skip {
+		schedule.logger.WithField("at", at).Info("Skipped a paused or skipped run")
+		return true
+	}
+	return !ignore && cs.Holidays.Skip(schedule.Command.Fields[0], "power "+schedule.Power, at)
+}
 
-		if newSched {
-			err = cs.writeSchedtoDB(record)
-			l := cs.Logger.WithFields(log.Fields{
-				"id":   id,
-				"name": name,
-			})
-			if err != nil {
-				l.WithError(err).Error("Cannot add schedule to db")
-			} else {
-				l.Info("Added schedule to db")
+// pausedAt tells whether the schedule is paused at a time. Call it with the
+// lock held.
+func (s *Schedule) pausedAt(at time.Time) bool {
+	return s.Paused && (s.PausedUntil == nil || at.Before(*s.PausedUntil))
+}
+
+// ContPause stops a schedule from running until it is resumed, or until the
+// start of a day if until isn't nil.
+func (cs *ControllerSchedule) ContPause(id string, until *time.Time) error {
+	return cs.update(id, func(s *Schedule) {
+		s.Paused, s.PausedUntil = true, until
+	})
+}
+
+// ContResume undoes a pause and a skipped run. It tells whether there was
+// anything to undo.
+func (cs *ControllerSchedule) ContResume(id string) (resumed bool, err error) {
+	err = cs.update(id, func(s *Schedule) {
+		resumed = s.pausedAt(functions.Now()) || s.SkipAt != nil
+		s.Paused, s.PausedUntil, s.SkipAt = false, nil, nil
+	})
+	return resumed, err
+}
+
+// ContSkipNext skips the next run of a schedule that isn't already skipped
+// for a holiday or a pause.
+func (cs *ControllerSchedule) ContSkipNext(id string) (at time.Time, err error) {
+	at = cs.NextRun(id)
+	if at.IsZero() {
+		return at, errors.New("scheduled task `" + id + "` is paused, there is no run to skip")
+	}
+	return at, cs.update(id, func(s *Schedule) {
+		s.SkipAt = &at
+	})
+}
+
+// NextRun gives the time the schedule runs next, or the zero time if it is
+// paused with no end.
+func (cs *ControllerSchedule) NextRun(id string) (next time.Time) {
+	schedule, ok := cs.Sched[id]
+	if !ok {
+		return next
+	}
+	s, err := cron.ParseStandard(schedule.CronExp)
+	if err != nil {
+		return next
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	next = functions.Now()
+	for i := 0; i < maxCronRuns; i++ {
+		next = s.Next(next)
+		if schedule.pausedAt(next) {
+			if schedule.PausedUntil == nil {
+				return time.Time{}
 			}
+			next = schedule.PausedUntil.Add(-time.Second)
+			continue
 		}
-
-		sch := &Schedule{
-			scheduleRecord: record,
-			scheduler:      s,
-			logger:         cs.Logger.WithField("job", name),
+		skipped := schedule.SkipAt != nil && schedule.SkipAt.Equal(next)
+		if _, closed := cs.Holidays.Closed(next); !skipped && (schedule.IgnoreHolidays || !closed) {
+			return next
 		}
+	}
+	return time.Time{}
+}
 
-		if err == nil {
-			cs.Sched[powerVal] = sch
-			cs.Set = true
+// update changes a schedule and saves it. RefreshPowerMessage shows the
+// change in the power message.
+func (cs *ControllerSchedule) update(id string, change func(s *Schedule)) error {
+	schedule, ok := cs.Sched[id]
+	if !ok {
+		return errors.New("there is no schedule with the ID " + id)
+	}
+	cs.mu.Lock()
+	change(schedule)
+	record := schedule.scheduleRecord
+	cs.mu.Unlock()
+	return cs.updateSchedInDB(record)
+}
+
+// ContSetHolidays sets whether a schedule is skipped on holidays.
+func (cs *ControllerSchedule) ContSetHolidays(id string, respect bool) error {
+	return cs.update(id, func(s *Schedule) {
+		s.IgnoreHolidays = !respect
+	})
+}
+
+// ContIDs lists the IDs of the running schedules, in the order they are
+// described.
+func (cs *ControllerSchedule) ContIDs() (ids []string) {
+	for _, schedule := range cs.running() {
+		ids = append(ids, schedule.ID)
+	}
+	return ids
+}
+
+// running lists the schedules that are running, off before on and then by
+// which runs next.
+func (cs *ControllerSchedule) running() (schedules []*Schedule) {
+	next := make(map[string]time.Time)
+	now := functions.Now()
+	for id, schedule := range cs.Sched {
+		if schedule != nil && schedule.scheduler != nil && schedule.scheduler.IsRunning() {
+			schedules = append(schedules, schedule)
+			if s, err := cron.ParseStandard(schedule.CronExp); err == nil {
+				next[id] = s.Next(now)
+			}
 		}
-		return err
 	}
+	sort.Slice(schedules, func(i, j int) bool {
+		a, b := schedules[i], schedules[j]
+		switch {
+		case a.Power != b.Power:
+			return a.Power < b.Power
+		case !next[a.ID].Equal(next[b.ID]):
+			return next[a.ID].Before(next[b.ID])
+		}
+		return a.ID < b.ID
+	})
+	return schedules
 }
 
-func (cs *ControllerSchedule) ContRemove(command slack.CommandInfo) (err error) {
-	powerVal := command.Fields[2]
-	if cs.Sched[powerVal] != nil && cs.Sched[powerVal].scheduler != nil && cs.Sched[powerVal].scheduler.IsRunning() {
-		cs.Sched[powerVal].scheduler.Stop()
-		// schedChan <- cs.onSched
+// skipMargin is how far a run can be from the time it was skipped at.
+const skipMargin = time.Minute
+
+type DueCommand struct {
+	ID      string
+	At      time.Time
+	Command slack.CommandInfo
+}
 
-		err = cs.deleteSchedfromDB(cs.Sched[powerVal].scheduleRecord)
+// DueCommands lists the commands the running schedules would have sent in (from, to].
+func (cs *ControllerSchedule) DueCommands(from time.Time, to time.Time) (due []DueCommand, err error) {
+	for _, schedule := range cs.Sched {
+		if schedule == nil || schedule.scheduler == nil || !schedule.scheduler.IsRunning() {
+			continue
+		}
+		runs, err := cronRuns(schedule.CronExp, from, to)
 		if err != nil {
-			cs.Logger.WithField("id", cs.Sched[powerVal].scheduleRecord.ID).Error("Could not delete schedule from database")
-		} else {
-			cs.Logger.WithField("id", cs.Sched[powerVal].scheduleRecord.ID).Info("Deleted schedule from database")
+			return due, err
+		}
+		for _, run := range runs {
+			due = append(due, DueCommand{
+				ID:      schedule.ID,
+				At:      run,
+				Command: scheduledCommand(schedule.Command),
+			})
 		}
-		delete(cs.Sched, powerVal)
-		if len(cs.Sched) == 0 {
-			cs.Set = false
-			cs.DeletePowerMessage()
+	}
+	return due, nil
+}
+
+func scheduledCommand(command slack.CommandInfo) slack.CommandInfo {
+	return slack.CommandInfo{
+		Fields:    []string{command.Fields[0], command.Fields[2]},
+		Channel:   command.Channel,
+		Scheduled: true,
+	}
+}
+
+// ContFind looks up a schedule by its ID or label, or by its power value
+// when there is only one schedule for it.
+func (cs *ControllerSchedule) ContFind(ref string) (id string, err error) {
+	var matches []string
+	for _, schedule := range cs.running() {
+		switch {
+		case schedule.ID == ref || strings.EqualFold(schedule.Label, ref):
+			return schedule.ID, nil
+		case schedule.Power == strings.ToLower(ref):
+			matches = append(matches, schedule.ID)
 		}
-		return nil
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) > 1:
+		return "", fmt.Errorf("there are %d scheduled %s tasks, remove one by its ID from `schedule list`", len(matches), strings.ToLower(ref))
+	case ref == "on" || ref == "off":
+		return "", errors.New("there is no scheduled " + ref + " task")
+	}
+	return "", errors.New("there is no schedule with the ID " + ref)
+}
+
+func (cs *ControllerSchedule) ContRemove(id string) (err error) {
+	schedule := cs.Sched[id]
+	if schedule == nil || schedule.scheduler == nil || !schedule.scheduler.IsRunning() {
+		return errors.New("there is no schedule with the ID " + id)
+	}
+	schedule.scheduler.Stop()
+
+	err = cs.deleteSchedfromDB(schedule.scheduleRecord)
+	if err != nil {
+		cs.Logger.WithField("id", id).Error("Could not delete schedule from database")
 	} else {
-		return errors.New("there is no scheduled " + powerVal + " task")
+		cs.Logger.WithField("id", id).Info("Deleted schedule from database")
+	}
+	delete(cs.Sched, id)
+	if len(cs.Sched) == 0 {
+		cs.Set = false
+		cs.DeletePowerMessage()
 	}
+	return nil
 }
 
+// ContGetSchedulingStatus describes each schedule on a line, with its label
+// if it has one.
 func (cs *ControllerSchedule) ContGetSchedulingStatus() string {
+	return cs.describeSchedules(false)
+}
+
+// ContList is like ContGetSchedulingStatus, but with the IDs of the schedules.
+func (cs *ControllerSchedule) ContList() string {
+	return cs.describeSchedules(true)
+}
+
+func (cs *ControllerSchedule) describeSchedules(withIDs bool) string {
 	var status strings.Builder
 	exprDesc, err := crondesc.NewDescriptor()
 	if err != nil {
@@ -113,19 +389,31 @@ func (cs *ControllerSchedule) ContGetSchedulingStatus() string {
 		return "*Scheduling*: " + message
 	}
 
-	for key, schedule := range cs.Sched {
-		if schedule != nil && schedule.scheduler != nil && schedule.scheduler.IsRunning() {
-			status.WriteString("*Scheduled " + strings.Title(key) + "*: ")
-			onText, err := exprDesc.ToDescription(schedule.CronExp, crondesc.Locale_en)
-			if err != nil {
-				message := "could not generate plain text for scheduled " + key
-				cs.Logger.WithField("err", err).Error(message)
-				status.WriteString(message)
-			} else {
-				status.WriteString(onText)
-			}
-			status.WriteString("\n")
+	for _, schedule := range cs.running() {
+		if withIDs {
+			status.WriteString("`" + schedule.ID + "` ")
 		}
+		status.WriteString("*Scheduled " + strings.Title(schedule.Power) + "*")
+		if schedule.Label != "" {
+			status.WriteString(" (" + schedule.Label + ")")
+		}
+		status.WriteString(": ")
+		cs.mu.Lock()
+		ignoreHolidays := schedule.IgnoreHolidays
+		cs.mu.Unlock()
+		text, err := exprDesc.ToDescription(schedule.CronExp, crondesc.Locale_en)
+		if err != nil {
+			message := "could not generate plain text for scheduled " + schedule.Power
+			cs.Logger.WithField("err", err).Error(message)
+			status.WriteString(message)
+		} else {
+			status.WriteString(text)
+		}
+		if ignoreHolidays {
+			status.WriteString(", also on holidays")
+		}
+		status.WriteString(cs.pauseStatus(schedule))
+		status.WriteString("\n")
 	}
 
 	if status.Len() == 0 {
@@ -135,6 +423,9 @@ func (cs *ControllerSchedule) ContGetSchedulingStatus() string {
 	return status.String()
 }
 
+// LoadSchedsfromDB reads the schedule records. Records from before
+// controllers could have several schedules have no power value of their
+// own, so it is filled in from the command and saved back.
 func (cs *ControllerSchedule) LoadSchedsfromDB() (records []scheduleRecord, err error) {
 	_, values, err := db.GetAllKeysValues(append(cs.DbPath, "records"))
 	if err != nil {
@@ -144,10 +435,22 @@ func (cs *ControllerSchedule) LoadSchedsfromDB() (records []scheduleRecord, err
 	for _, value := range values {
 		var record scheduleRecord
 		err = json.Unmarshal(value, &record)
+		if err != nil {
+			cs.Logger.WithError(err).Error("Cannot parse schedule in database")
+			continue
+		}
 		cs.Logger.WithField("id", record.ID).Info("Parsed schedule in database")
+		if record.Power == "" && len(record.Command.Fields) > 2 {
+			record.Power = record.Command.Fields[2]
+			if e := cs.updateSchedInDB(record); e != nil {
+				cs.Logger.WithError(e).WithField("id", record.ID).Error("Cannot migrate schedule in database")
+			} else {
+				cs.Logger.WithField("id", record.ID).Info("Migrated schedule in database")
+			}
+		}
 		records = append(records, record)
 	}
-	return records, err
+	return records, nil
 }
 
 func (cs *ControllerSchedule) writeSchedtoDB(record scheduleRecord) (err error) {
@@ -174,11 +477,75 @@ func (cs *ControllerSchedule) writeSchedtoDB(record scheduleRecord) (err error)
 	return err
 }
 
+func (cs *ControllerSchedule) updateSchedInDB(record scheduleRecord) (err error) {
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return db.AddValue(append(cs.DbPath, "records"), record.ID, buf)
+}
+
 func (cs *ControllerSchedule) deleteSchedfromDB(record scheduleRecord) (err error) {
 	err = db.DeleteValue(append(cs.DbPath, "records"), record.ID)
 	return err
 }
 
+// pauseStatus describes whether a schedule is paused or has a run skipped.
+func (cs *ControllerSchedule) pauseStatus(schedule *Schedule) string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	now := functions.Now()
+	var status string
+	switch {
+	case schedule.pausedAt(now) && schedule.PausedUntil != nil:
+		status += ", *paused* until " + schedule.PausedUntil.Format("Mon Jan 2")
+	case schedule.pausedAt(now):
+		status += ", *paused*"
+	}
+	if schedule.SkipAt != nil && schedule.SkipAt.After(now) {
+		status += ", skipping the run at " + schedule.SkipAt.Format("Mon Jan 2 3:04 PM")
+	}
+	return status
+}
+
+// powerMessageNote tells in the power message which schedules are paused or
+// skipping their next run.
+func (cs *ControllerSchedule) powerMessageNote() string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	now := functions.Now()
+	var paused, total int
+	var until []time.Time
+	var notes []string
+	for _, schedule := range cs.Sched {
+		total++
+		if schedule.pausedAt(now) {
+			paused++
+			if schedule.PausedUntil != nil {
+				until = append(until, *schedule.PausedUntil)
+			}
+		}
+		if schedule.SkipAt != nil && schedule.SkipAt.After(now) {
+			notes = append(notes, "skipping the "+schedule.Power+" at "+schedule.SkipAt.Format("Mon 3:04 PM"))
+		}
+	}
+	sort.Strings(notes)
+
+	switch {
+	case paused == 0:
+	case paused < total:
+		notes = append([]string{fmt.Sprintf("%d of %d schedules paused", paused, total)}, notes...)
+	case len(until) == paused && until[0].Equal(until[len(until)-1]):
+		notes = append([]string{"schedules paused until " + until[0].Format("Mon Jan 2")}, notes...)
+	default:
+		notes = append([]string{"schedules paused"}, notes...)
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " _(" + strings.Join(notes, ", ") + ")_"
+}
+
 func (cs *ControllerSchedule) LoadPowerMessagefromDB() error {
 	var readMessageChannel []byte
 	readTimestamp, err := db.ReadValue(cs.DbPath, "PowerMessageTimestamp")
@@ -211,9 +578,10 @@ func (cs *ControllerSchedule) LoadPowerMessagefromDB() error {
 
 func (cs *ControllerSchedule) PostPowerMessage(channel string, name string, status string) (err error) {
 	cs.powerMessageChannel = channel
-	cs.powerMessageTimestamp, err = slack.PostMessage(channel, name+": "+status)
+	cs.powerMessageName, cs.powerMessageStatus, cs.powerMessageNoted = name, status, cs.powerMessageNote()
+	cs.powerMessageTimestamp, err = cs.Messenger.PostMessage(channel, name+": "+status+cs.powerMessageNoted)
 	if err == nil {
-		slack.PinMessage(cs.powerMessageChannel, cs.powerMessageTimestamp)
+		cs.Messenger.PinMessage(cs.powerMessageChannel, cs.powerMessageTimestamp)
 		db.AddValue(cs.DbPath, "PowerMessageTimestamp", []byte(cs.powerMessageTimestamp))
 		db.AddValue(cs.DbPath, "PowerMessageChannel", []byte(cs.powerMessageChannel))
 
@@ -228,7 +596,7 @@ func (cs *ControllerSchedule) PostPowerMessage(channel string, name string, stat
 }
 
 func (cs *ControllerSchedule) DeletePowerMessage() error {
-	err := slack.DeleteMessage(cs.powerMessageChannel, cs.powerMessageTimestamp)
+	err := cs.Messenger.DeleteMessage(cs.powerMessageChannel, cs.powerMessageTimestamp)
 	if err == nil {
 		db.DeleteValue(cs.DbPath, "PowerMessageTimestamp")
 		db.DeleteValue(cs.DbPath, "PowerMessageChannel")
@@ -245,14 +613,14 @@ func (cs *ControllerSchedule) DeleteOtherPowerMessage(name string, timestamp str
 		return errors.New(errorMsg)
 	}
 
-	pinnedMessages, err := slack.ListPins(cs.powerMessageChannel)
+	pinnedMessages, err := cs.Messenger.ListPins(cs.powerMessageChannel)
 	if err == nil {
 		timestamps := functions.GetKeys(pinnedMessages)
 		var numDeleted int
 		if len(timestamps) != 0 {
 			for _, timestamp := range timestamps {
 				if timestamp != cs.powerMessageTimestamp && strings.Contains(pinnedMessages[timestamp], name) {
-					slack.DeleteMessage(cs.powerMessageChannel, timestamp)
+					cs.Messenger.DeleteMessage(cs.powerMessageChannel, timestamp)
 					numDeleted++
 				}
 			}
@@ -267,7 +635,8 @@ func (cs *ControllerSchedule) DeleteOtherPowerMessage(name string, timestamp str
 }
 
 func (cs *ControllerSchedule) ModifyPowerMessage(name string, status string) error {
-	err := slack.ModifyMessage(cs.powerMessageChannel, cs.powerMessageTimestamp, name+": "+status)
+	cs.powerMessageName, cs.powerMessageStatus, cs.powerMessageNoted = name, status, cs.powerMessageNote()
+	err := cs.Messenger.ModifyMessage(cs.powerMessageChannel, cs.powerMessageTimestamp, name+": "+status+cs.powerMessageNoted)
 	if err != nil {
 		cs.Logger.WithFields(log.Fields{
 			"channel":   cs.powerMessageChannel,
@@ -283,3 +652,11 @@ func (cs *ControllerSchedule) ModifyPowerMessage(name string, status string) err
 	}
 	return err
 }
+
+// RefreshPowerMessage updates the power message when a schedule changed
+// what it should say.
+func (cs *ControllerSchedule) RefreshPowerMessage() {
+	if cs.powerMessageTimestamp != "" && cs.powerMessageName != "" && cs.powerMessageNote() != cs.powerMessageNoted {
+		cs.ModifyPowerMessage(cs.powerMessageName, cs.powerMessageStatus)
+	}
+}
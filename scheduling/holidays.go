@@ -0,0 +1,375 @@
+package scheduling
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vishhvaan/lab-bot/db"
+	"github.com/vishhvaan/lab-bot/functions"
+)
+
+// The holiday calendar lists the days the lab is closed. Scheduled work that
+// respects holidays checks it before running and is skipped on those days.
+// Closures come from the config, which can point to an ICS file or a YAML
+// list of dates, and from chat, which are kept in the database.
+
+const (
+	dateLayout     = "2006-01-02"
+	maxSkippedRuns = 50
+)
+
+type Closure struct {
+	Start time.Time
+	End   time.Time
+	Name  string `json:",omitempty"`
+	fixed bool
+}
+
+type SkippedRun struct {
+	Job     string
+	What    string
+	At      time.Time
+	Closure Closure
+}
+
+type Calendar struct {
+	mu      sync.Mutex
+	fixed   []Closure
+	added   []Closure
+	skipped []SkippedRun
+	dbPath  []string
+	Logger  *log.Entry
+}
+
+func NewCalendar(logger *log.Entry) *Calendar {
+	return &Calendar{Logger: logger}
+}
+
+// ID is how a closure is written and removed: its first day, or its first
+// and last day.
+func (c Closure) ID() string {
+	if c.End.Equal(c.Start) {
+		return c.Start.Format(dateLayout)
+	}
+	return c.Start.Format(dateLayout) + ".." + c.End.Format(dateLayout)
+}
+
+func (c Closure) String() string {
+	var text string
+	if c.End.Equal(c.Start) {
+		text = c.Start.Format("Mon Jan 2 2006")
+	} else {
+		text = c.Start.Format("Mon Jan 2 2006") + " to " + c.End.Format("Mon Jan 2 2006")
+	}
+	if c.Name != "" {
+		text += " (" + c.Name + ")"
+	}
+	return text
+}
+
+func (c Closure) covers(t time.Time) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	return !day.Before(c.Start) && !day.After(c.End)
+}
+
+// ParseClosure reads "<date>[..<date>] [name]", with dates like 2026-12-24.
+func ParseClosure(text string) (c Closure, err error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return c, errors.New("which days? Like 2026-12-24 or 2026-12-24..2027-01-02")
+	}
+	days := strings.SplitN(fields[0], "..", 2)
+	c.Start, err = time.ParseInLocation(dateLayout, days[0], time.Local)
+	if err != nil {
+		return c, errors.New("I couldn't read " + days[0] + " as a date, write it like 2026-12-24")
+	}
+	c.End = c.Start
+	if len(days) == 2 {
+		c.End, err = time.ParseInLocation(dateLayout, days[1], time.Local)
+		if err != nil {
+			return c, errors.New("I couldn't read " + days[1] + " as a date, write it like 2027-01-02")
+		}
+		if c.End.Before(c.Start) {
+			return c, errors.New("the closure ends before it starts")
+		}
+	}
+	c.Name = strings.Join(fields[1:], " ")
+	return c, nil
+}
+
+// LoadFixed adds the closures from the config: entries in the same form as
+// ParseClosure, and a file, which is an ICS calendar or a YAML list of
+// entries.
+func (cal *Calendar) LoadFixed(file string, entries []string) error {
+	if file != "" {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".ics":
+			closures, err := parseICS(buf)
+			if err != nil {
+				return err
+			}
+			cal.addFixed(closures)
+		default:
+			var fileEntries []string
+			if err := yaml.Unmarshal(buf, &fileEntries); err != nil {
+				return errors.New(file + " should be an .ics calendar or a YAML list of dates: " + err.Error())
+			}
+			entries = append(entries, fileEntries...)
+		}
+	}
+
+	var closures []Closure
+	for _, entry := range entries {
+		c, err := ParseClosure(entry)
+		if err != nil {
+			return errors.New("holiday \"" + entry + "\": " + err.Error())
+		}
+		closures = append(closures, c)
+	}
+	cal.addFixed(closures)
+	return nil
+}
+
+func (cal *Calendar) addFixed(closures []Closure) {
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	for _, c := range closures {
+		c.fixed = true
+		cal.fixed = append(cal.fixed, c)
+	}
+}
+
+// parseICS reads the all-day and timed events of an ICS calendar as closures
+// of the days they cover. Repeating events only count once.
+func parseICS(buf []byte) (closures []Closure, err error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// long lines are folded onto lines starting with a space or tab
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var c Closure
+	var inEvent, hasEnd, endsAtMidnight bool
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(name, ";")
+		switch strings.ToUpper(params[0]) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				c, inEvent, hasEnd = Closure{}, true, false
+			}
+		case "DTSTART":
+			if inEvent {
+				c.Start, _, err = parseICSDate(value)
+			}
+		case "DTEND":
+			if inEvent {
+				c.End, endsAtMidnight, err = parseICSDate(value)
+				hasEnd = true
+			}
+		case "SUMMARY":
+			if inEvent {
+				c.Name = strings.ReplaceAll(value, "\\,", ",")
+			}
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if c.Start.IsZero() {
+				return nil, errors.New("calendar event without a start")
+			}
+			switch {
+			case !hasEnd:
+				c.End = c.Start
+			case endsAtMidnight:
+				// the end is exclusive
+				c.End = c.End.AddDate(0, 0, -1)
+			}
+			if c.End.Before(c.Start) {
+				c.End = c.Start
+			}
+			closures = append(closures, c)
+		}
+		if err != nil {
+			return nil, errors.New("calendar date " + value + " isn't valid")
+		}
+	}
+	return closures, nil
+}
+
+// parseICSDate returns the day of an ICS date or date-time, and whether it
+// is the very start of that day, as dates are.
+func parseICSDate(value string) (day time.Time, midnight bool, err error) {
+	if len(value) == 8 {
+		day, err = time.ParseInLocation("20060102", value, time.Local)
+		return day, true, err
+	}
+	var t time.Time
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		t = t.Local()
+	} else {
+		t, err = time.ParseInLocation("20060102T150405", value, time.Local)
+	}
+	day = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	return day, t.Equal(day), err
+}
+
+func (cal *Calendar) Load(dbPath []string) error {
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	cal.dbPath = dbPath
+	cal.added = nil
+	if !db.CheckBucketExists(dbPath) {
+		return db.CreateBucket(dbPath)
+	}
+	return db.RunCallbackOnEachKey(dbPath, func(key []byte, value []byte) error {
+		var c Closure
+		if err := json.Unmarshal(value, &c); err != nil {
+			return err
+		}
+		cal.added = append(cal.added, c)
+		return nil
+	})
+}
+
+func (cal *Calendar) Add(c Closure) error {
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	for _, existing := range cal.added {
+		if existing.ID() == c.ID() {
+			return errors.New("that closure is already on the calendar")
+		}
+	}
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = db.AddValue(cal.dbPath, c.ID(), buf); err != nil {
+		return err
+	}
+	cal.added = append(cal.added, c)
+	return nil
+}
+
+// Remove takes a closure added in chat off the calendar, by its ID or first
+// day.
+func (cal *Calendar) Remove(id string) (removed Closure, err error) {
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	for i, c := range cal.added {
+		if c.ID() == id || c.Start.Format(dateLayout) == id {
+			if err = db.DeleteValue(cal.dbPath, c.ID()); err != nil {
+				return c, err
+			}
+			cal.added = append(cal.added[:i], cal.added[i+1:]...)
+			return c, nil
+		}
+	}
+	for _, c := range cal.fixed {
+		if c.ID() == id || c.Start.Format(dateLayout) == id {
+			return c, errors.New("that closure comes from the config file, remove it there")
+		}
+	}
+	return removed, errors.New("there is no closure " + id + " on the calendar")
+}
+
+// Closed tells whether the lab is closed on the day of t.
+func (cal *Calendar) Closed(t time.Time) (closure Closure, closed bool) {
+	if cal == nil {
+		return closure, false
+	}
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	for _, c := range append(append([]Closure{}, cal.fixed...), cal.added...) {
+		if c.covers(t) {
+			return c, true
+		}
+	}
+	return closure, false
+}
+
+// Skip tells whether scheduled work due at t falls on a closure, and if so
+// notes it down as skipped.
+func (cal *Calendar) Skip(job string, what string, at time.Time) bool {
+	closure, closed := cal.Closed(at)
+	if !closed {
+		return false
+	}
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	cal.skipped = append(cal.skipped, SkippedRun{Job: job, What: what, At: at, Closure: closure})
+	if len(cal.skipped) > maxSkippedRuns {
+		cal.skipped = cal.skipped[len(cal.skipped)-maxSkippedRuns:]
+	}
+	cal.Logger.WithFields(log.Fields{
+		"job":     job,
+		"at":      at,
+		"closure": closure.ID(),
+	}).Info("Skipped " + what + " for a holiday")
+	return true
+}
+
+// Skipped lists the runs skipped since a time, for one job or for all of
+// them if job is empty.
+func (cal *Calendar) Skipped(job string, since time.Time) (runs []SkippedRun) {
+	if cal == nil {
+		return nil
+	}
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	for _, run := range cal.skipped {
+		if (job == "" || run.Job == job) && run.At.After(since) {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// Upcoming lists the closures that haven't ended yet, soonest first.
+func (cal *Calendar) Upcoming() (closures []Closure) {
+	cal.mu.Lock()
+	defer cal.mu.Unlock()
+	now := functions.Now()
+	for _, c := range append(append([]Closure{}, cal.fixed...), cal.added...) {
+		if !c.End.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)) {
+			closures = append(closures, c)
+		}
+	}
+	sort.SliceStable(closures, func(i, j int) bool {
+		return closures[i].Start.Before(closures[j].Start)
+	})
+	return closures
+}
+
+func (c Closure) Fixed() bool {
+	return c.fixed
+}
@@ -0,0 +1,153 @@
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	crondesc "github.com/lnquy/cron"
+	"github.com/robfig/cron/v3"
+
+	"github.com/vishhvaan/lab-bot/functions"
+)
+
+// Schedules can be written as phrases like "every weekday at 8am" or
+// "daily at 6:30pm" instead of cron. A phrase is a list of days and times
+// in any order, with filler words like every, on and at ignored.
+
+var phraseFillers = []string{"every", "each", "on", "at", "and", "the"}
+
+var phraseDays = map[string][]int{
+	"day":      {0, 1, 2, 3, 4, 5, 6},
+	"days":     {0, 1, 2, 3, 4, 5, 6},
+	"daily":    {0, 1, 2, 3, 4, 5, 6},
+	"everyday": {0, 1, 2, 3, 4, 5, 6},
+	"weekday":  {1, 2, 3, 4, 5},
+	"weekdays": {1, 2, 3, 4, 5},
+	"weekend":  {0, 6},
+	"weekends": {0, 6},
+}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		for _, n := range []string{name, name + "s", name[:3]} {
+			phraseDays[n] = []int{int(d)}
+		}
+	}
+}
+
+// ParseSchedule turns a schedule into cron. Cron expressions are returned
+// as they are; phrase tells whether the schedule had to be translated.
+func ParseSchedule(text string) (cronExp string, phrase bool, err error) {
+	if _, err := cron.ParseStandard(text); err == nil {
+		return text, false, nil
+	}
+	cronExp, err = phraseToCron(text)
+	return cronExp, true, err
+}
+
+func phraseToCron(text string) (cronExp string, err error) {
+	var tokens []string
+	for _, token := range strings.Fields(strings.ToLower(strings.ReplaceAll(text, ",", " "))) {
+		if (token == "am" || token == "pm") && len(tokens) > 0 {
+			tokens[len(tokens)-1] += token
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	days := make(map[int]bool)
+	hours := make(map[int]bool)
+	minute := -1
+	hourly := false
+	for _, token := range tokens {
+		if functions.Contains(phraseFillers, token) {
+			continue
+		}
+		if d, ok := phraseDays[token]; ok {
+			for _, day := range d {
+				days[day] = true
+			}
+			continue
+		}
+		if token == "hour" || token == "hourly" {
+			hourly = true
+			continue
+		}
+		h, m, err := functions.ParseTimeOfDay(token)
+		if err != nil {
+			return "", errors.New("I don't understand \"" + token + "\" in the schedule")
+		}
+		if minute != -1 && m != minute {
+			return "", errors.New("times in one schedule need the same minutes, set them up as separate schedules")
+		}
+		hours[h] = true
+		minute = m
+	}
+
+	switch {
+	case hourly && len(hours) > 0:
+		return "", errors.New("a schedule can run every hour or at a time, not both")
+	case hourly:
+		return "0 * * * " + cronList(days, 7), nil
+	case len(hours) == 0:
+		return "", errors.New("at what time? Like `every weekday at 8am`")
+	}
+	return fmt.Sprintf("%d %s * * %s", minute, cronList(hours, 24), cronList(days, 7)), nil
+}
+
+// cronList writes a set of values as a cron field, with runs of three or
+// more as ranges, or * when the set is empty or has every value.
+func cronList(set map[int]bool, size int) string {
+	if len(set) == 0 || len(set) == size {
+		return "*"
+	}
+	values := functions.GetKeys(set)
+	sort.Ints(values)
+
+	var parts []string
+	for i := 0; i < len(values); {
+		j := i
+		for j+1 < len(values) && values[j+1] == values[j]+1 {
+			j++
+		}
+		switch {
+		case j-i >= 2:
+			parts = append(parts, strconv.Itoa(values[i])+"-"+strconv.Itoa(values[j]))
+		case j > i:
+			parts = append(parts, strconv.Itoa(values[i]), strconv.Itoa(values[j]))
+		default:
+			parts = append(parts, strconv.Itoa(values[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// DescribeCron gives the plain text for a cron expression and the next n
+// times it runs.
+func DescribeCron(cronExp string, n int) (description string, next []time.Time, err error) {
+	s, err := cron.ParseStandard(cronExp)
+	if err != nil {
+		return "", nil, err
+	}
+	exprDesc, err := crondesc.NewDescriptor()
+	if err != nil {
+		return "", nil, err
+	}
+	description, err = exprDesc.ToDescription(cronExp, crondesc.Locale_en)
+	if err != nil {
+		return "", nil, err
+	}
+
+	at := functions.Now()
+	for i := 0; i < n; i++ {
+		at = s.Next(at)
+		next = append(next, at)
+	}
+	return description, next, nil
+}
@@ -4,12 +4,13 @@ import (
 	"math/rand"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/slack-go/slack/slackevents"
 
 	"github.com/vishhvaan/lab-bot/functions"
 )
 
-type cb func(sc *slackClient, ev *slackevents.AppMentionEvent, fields []string)
+type cb func(m Messenger, ev *slackevents.AppMentionEvent, fields []string)
 
 var basicResponses = map[string]cb{
 	"hello": hello, "hai": hello, "hey": hello,
@@ -21,43 +22,59 @@ var basicResponses = map[string]cb{
 }
 
 func (sc *slackClient) commandInterpreter(ev *slackevents.AppMentionEvent) {
-	noUID := strings.ReplaceAll(ev.Text, "<@"+sc.bot.UserID+">", "")
+	if c, ok := HandleMention(sc, sc.bot.UserID, ev); ok {
+		CommandChan <- c
+	}
+}
+
+// HandleMention answers the basic responses directly. Anything else is
+// returned as a command for the job handler.
+func HandleMention(m Messenger, botUserID string, ev *slackevents.AppMentionEvent) (c CommandInfo, ok bool) {
+	if ev.User == "" {
+		// bots and workflows mention without a user, and can't be checked
+		log.WithField("bot", ev.BotID).Warn("Ignored a mention without a user")
+		return c, false
+	}
+	noUID := strings.ReplaceAll(ev.Text, "<@"+botUserID+">", "")
 	fields := strings.Fields(noUID)
 	if len(fields) == 0 {
-		sc.logger.Info("Bot simply mentioned, responding with hello")
-		hello(sc, ev, []string{""})
-	} else {
-		command := strings.ToLower(fields[0])
-		if functions.Contains(functions.GetKeys(basicResponses), command) {
-			f := basicResponses[command]
-			f(sc, ev, fields)
-		} else {
-			CommandChan <- CommandInfo{
-				Fields:    fields,
-				Channel:   ev.Channel,
-				TimeStamp: ev.TimeStamp,
-				User:      ev.User,
-			}
-		}
+		log.Info("Bot simply mentioned, responding with hello")
+		hello(m, ev, []string{""})
+		return c, false
 	}
+
+	command := strings.ToLower(fields[0])
+	if functions.Contains(functions.GetKeys(basicResponses), command) {
+		f := basicResponses[command]
+		f(m, ev, fields)
+		return c, false
+	}
+
+	return CommandInfo{
+		Fields:    fields,
+		Text:      strings.TrimSpace(noUID),
+		Channel:   ev.Channel,
+		TimeStamp: ev.TimeStamp,
+		User:      ev.User,
+	}, true
 }
 
-func hello(sc *slackClient, ev *slackevents.AppMentionEvent, fields []string) {
-	response := "Hello, " + sc.getUserName(ev.User) + "! :party_parrot:"
-	sc.PostMessage(ev.Channel, response)
+func hello(m Messenger, ev *slackevents.AppMentionEvent, fields []string) {
+	response := "Hello, " + m.GetUserName(ev.User) + "! :party_parrot:"
+	m.PostMessage(ev.Channel, response)
 }
 
-func bye(sc *slackClient, ev *slackevents.AppMentionEvent, fields []string) {
-	response := "Goodbye, " + sc.getUserName(ev.User) + "! :wave:"
-	sc.PostMessage(ev.Channel, response)
+func bye(m Messenger, ev *slackevents.AppMentionEvent, fields []string) {
+	response := "Goodbye, " + m.GetUserName(ev.User) + "! :wave:"
+	m.PostMessage(ev.Channel, response)
 }
 
-func sysinfo(sc *slackClient, ev *slackevents.AppMentionEvent, fields []string) {
+func sysinfo(m Messenger, ev *slackevents.AppMentionEvent, fields []string) {
 	response := functions.GetSysInfo()
-	sc.PostMessage(ev.Channel, response)
+	m.PostMessage(ev.Channel, response)
 }
 
-func thanks(sc *slackClient, ev *slackevents.AppMentionEvent, fields []string) {
+func thanks(m Messenger, ev *slackevents.AppMentionEvent, fields []string) {
 	allResponses := []string{
 		"No problemo",
 		"May the force be with you",
@@ -67,5 +84,5 @@ func thanks(sc *slackClient, ev *slackevents.AppMentionEvent, fields []string) {
 		":meow_code:",
 	}
 	response := allResponses[rand.Intn(len(allResponses))]
-	sc.PostMessage(ev.Channel, response)
+	m.PostMessage(ev.Channel, response)
 }
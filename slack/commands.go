@@ -2,11 +2,17 @@ package slack
 
 var CommandChan = make(chan CommandInfo)
 
+// CommandInfo is a command sent to the bot. Text is the command as typed,
+// for parsers that need its quotes; it is empty for commands the bot makes
+// up itself, which only have Fields. Scheduled marks those commands, which
+// skip the permission checks their schedule was set up under.
 type CommandInfo struct {
 	Fields    []string
+	Text      string
 	Channel   string
 	TimeStamp string
 	User      string
+	Scheduled bool
 }
 
 func (sc *slackClient) RunSocketMode() {
@@ -21,6 +27,10 @@ func (sc *slackClient) getChannelName(channelID string) (channel string) {
 	return ch.Name
 }
 
+func (sc *slackClient) GetUserName(userID string) (user string) {
+	return sc.getUserName(userID)
+}
+
 func (sc *slackClient) getUserName(userID string) (user string) {
 	us, err := sc.api.GetUserInfo(userID)
 	if err != nil {
@@ -0,0 +1,145 @@
+package slack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+const (
+	ConsoleBotID   = "ULABBOT"
+	ConsoleChannel = "console"
+
+	consoleLimit = 500
+)
+
+// ConsoleMessenger prints everything the bot says to a terminal. Messages
+// and pins are kept in memory so edits and deletions still work, only the
+// latest ones so a console left running doesn't keep growing.
+type ConsoleMessenger struct {
+	*MemoryMessenger
+	out   io.Writer
+	outMu sync.Mutex
+}
+
+func NewConsoleMessenger(out io.Writer, botChannel string) *ConsoleMessenger {
+	cm := &ConsoleMessenger{
+		MemoryMessenger: NewMemoryMessenger(botChannel),
+		out:             out,
+	}
+	cm.Users[ConsoleBotID] = "lab-bot"
+	cm.Limit = consoleLimit
+	return cm
+}
+
+func (cm *ConsoleMessenger) print(channel string, action string, text string) {
+	cm.outMu.Lock()
+	defer cm.outMu.Unlock()
+	prefix := "[#" + channel + "] lab-bot"
+	if action != "" {
+		prefix += " (" + action + ")"
+	}
+	text = strings.ReplaceAll(text, "\n", "\n    ")
+	fmt.Fprintf(cm.out, "%s: %s\n", prefix, text)
+}
+
+func (cm *ConsoleMessenger) PostMessage(channelID string, text string) (timestamp string, err error) {
+	timestamp, err = cm.MemoryMessenger.PostMessage(channelID, text)
+	if err == nil {
+		cm.print(channelID, "", text)
+	}
+	return timestamp, err
+}
+
+func (cm *ConsoleMessenger) SendMessage(channel string, text string) (timestamp string, err error) {
+	timestamp, err = cm.MemoryMessenger.SendMessage(channel, text)
+	if err == nil {
+		cm.print(channel, "", text)
+	}
+	return timestamp, err
+}
+
+func (cm *ConsoleMessenger) Message(text string) (timestamp string, err error) {
+	return cm.PostMessage(cm.BotChannel, text)
+}
+
+func (cm *ConsoleMessenger) React(timestamp string, channelID string, text string) (err error) {
+	err = cm.MemoryMessenger.React(timestamp, channelID, text)
+	if err == nil {
+		cm.print(channelID, "reacted", ":"+text+":")
+	}
+	return err
+}
+
+func (cm *ConsoleMessenger) PinMessage(channelID string, timestamp string) (err error) {
+	err = cm.MemoryMessenger.PinMessage(channelID, timestamp)
+	if err == nil {
+		text, _ := cm.Text(channelID, timestamp)
+		cm.print(channelID, "pinned", text)
+	}
+	return err
+}
+
+func (cm *ConsoleMessenger) ModifyMessage(channelID string, timestamp string, text string) (err error) {
+	err = cm.MemoryMessenger.ModifyMessage(channelID, timestamp, text)
+	if err == nil {
+		cm.print(channelID, "edited", text)
+	}
+	return err
+}
+
+func (cm *ConsoleMessenger) DeleteMessage(channelID string, timestamp string) (err error) {
+	text, _ := cm.Text(channelID, timestamp)
+	err = cm.MemoryMessenger.DeleteMessage(channelID, timestamp)
+	if err == nil {
+		cm.print(channelID, "deleted", text)
+	}
+	return err
+}
+
+func (cm *ConsoleMessenger) UploadFile(channelID string, filePath string, title string) (err error) {
+	err = cm.MemoryMessenger.UploadFile(channelID, filePath, title)
+	if err == nil {
+		cm.print(channelID, "uploaded", filePath)
+	}
+	return err
+}
+
+// RunConsole reads one command per line, as it would be typed after
+// @lab-bot, and hands it to the bot like a Slack mention. It returns when
+// the input is closed.
+func RunConsole(cm *ConsoleMessenger, in io.Reader, userName string) error {
+	userID := "U" + strings.ToUpper(userName)
+	cm.Users[userID] = userName
+
+	scanner := bufio.NewScanner(in)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSpace(strings.TrimPrefix(line, "@lab-bot"))
+		if line == "" {
+			continue
+		}
+
+		ev := &slackevents.AppMentionEvent{
+			User:      userID,
+			Text:      "<@" + ConsoleBotID + "> " + EscapeText(line),
+			Channel:   ConsoleChannel,
+			TimeStamp: fmt.Sprintf("%010d.000100", i),
+		}
+		if c, ok := HandleMention(cm, ConsoleBotID, ev); ok {
+			CommandChan <- c
+		}
+	}
+	return scanner.Err()
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EscapeText escapes message text the way Slack does before delivering it.
+func EscapeText(text string) string {
+	return escaper.Replace(text)
+}
@@ -0,0 +1,226 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type RecordedCall struct {
+	Method    string
+	Channel   string
+	Timestamp string
+	Text      string
+}
+
+// MemoryMessenger keeps messages and pins in memory and records every call
+// made through it, in order. With a Limit it only keeps about that many of
+// the latest calls, and of the unpinned messages in each channel, for a bot
+// that runs for long.
+type MemoryMessenger struct {
+	BotChannel string
+	Users      map[string]string
+	Limit      int
+	mu         sync.Mutex
+	calls      []RecordedCall
+	messages   map[string]map[string]string
+	pins       map[string]map[string]bool
+	counter    int
+}
+
+func NewMemoryMessenger(botChannel string) *MemoryMessenger {
+	return &MemoryMessenger{
+		BotChannel: botChannel,
+		Users:      make(map[string]string),
+		messages:   make(map[string]map[string]string),
+		pins:       make(map[string]map[string]bool),
+	}
+}
+
+func (mm *MemoryMessenger) Calls() []RecordedCall {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	calls := make([]RecordedCall, len(mm.calls))
+	copy(calls, mm.calls)
+	return calls
+}
+
+func (mm *MemoryMessenger) Reset() {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.calls = nil
+}
+
+func (mm *MemoryMessenger) record(method string, channel string, timestamp string, text string) {
+	if mm.Limit > 0 && len(mm.calls) >= 2*mm.Limit {
+		mm.calls = append([]RecordedCall(nil), mm.calls[len(mm.calls)-mm.Limit:]...)
+	}
+	mm.calls = append(mm.calls, RecordedCall{
+		Method:    method,
+		Channel:   channel,
+		Timestamp: timestamp,
+		Text:      text,
+	})
+}
+
+func (mm *MemoryMessenger) nextTimestamp() string {
+	mm.counter++
+	return fmt.Sprintf("%010d.000000", mm.counter)
+}
+
+func (mm *MemoryMessenger) store(channel string, text string) (timestamp string) {
+	timestamp = mm.nextTimestamp()
+	if mm.messages[channel] == nil {
+		mm.messages[channel] = make(map[string]string)
+	}
+	mm.messages[channel][timestamp] = text
+	if mm.Limit > 0 && len(mm.messages[channel]) > mm.Limit {
+		mm.forgetOldest(channel)
+	}
+	return timestamp
+}
+
+// forgetOldest drops the oldest message in a channel that isn't pinned.
+func (mm *MemoryMessenger) forgetOldest(channel string) {
+	oldest := ""
+	for timestamp := range mm.messages[channel] {
+		if !mm.pins[channel][timestamp] && (oldest == "" || timestamp < oldest) {
+			oldest = timestamp
+		}
+	}
+	delete(mm.messages[channel], oldest)
+}
+
+func (mm *MemoryMessenger) lookup(channelID string, timestamp string) (text string, err error) {
+	text, ok := mm.messages[channelID][timestamp]
+	if !ok {
+		return "", errors.New("message_not_found")
+	}
+	return text, nil
+}
+
+// Text returns the current text of a message, following any edits.
+func (mm *MemoryMessenger) Text(channelID string, timestamp string) (text string, err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return mm.lookup(channelID, timestamp)
+}
+
+func (mm *MemoryMessenger) PostMessage(channelID string, text string) (timestamp string, err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	timestamp = mm.store(channelID, text)
+	mm.record("PostMessage", channelID, timestamp, text)
+	return timestamp, nil
+}
+
+func (mm *MemoryMessenger) SendMessage(channel string, text string) (timestamp string, err error) {
+	if text == "" {
+		return "", errors.New("cannot send empty message")
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	timestamp = mm.store(channel, text)
+	mm.record("SendMessage", channel, timestamp, text)
+	return timestamp, nil
+}
+
+func (mm *MemoryMessenger) Message(text string) (timestamp string, err error) {
+	return mm.PostMessage(mm.BotChannel, text)
+}
+
+func (mm *MemoryMessenger) React(timestamp string, channelID string, text string) (err error) {
+	if timestamp == "" {
+		return errors.New("cannot react to nonexistent message")
+	} else if channelID == "" {
+		return errors.New("need channelID to react")
+	} else if text == "" {
+		return errors.New("need something to react with")
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.record("React", channelID, timestamp, text)
+	return nil
+}
+
+func (mm *MemoryMessenger) PinMessage(channelID string, timestamp string) (err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	text, err := mm.lookup(channelID, timestamp)
+	if err != nil {
+		return err
+	}
+	if mm.pins[channelID] == nil {
+		mm.pins[channelID] = make(map[string]bool)
+	}
+	mm.pins[channelID][timestamp] = true
+	mm.record("PinMessage", channelID, timestamp, text)
+	return nil
+}
+
+func (mm *MemoryMessenger) ListPins(channelID string) (pinnedMessages map[string]string, err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	pinnedMessages = make(map[string]string)
+	for timestamp := range mm.pins[channelID] {
+		pinnedMessages[timestamp] = mm.messages[channelID][timestamp]
+	}
+	mm.record("ListPins", channelID, "", "")
+	return pinnedMessages, nil
+}
+
+// Pins returns the text of the pinned messages in a channel, oldest first.
+func (mm *MemoryMessenger) Pins(channelID string) (texts []string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	var timestamps []string
+	for timestamp := range mm.pins[channelID] {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Strings(timestamps)
+	for _, timestamp := range timestamps {
+		texts = append(texts, mm.messages[channelID][timestamp])
+	}
+	return texts
+}
+
+func (mm *MemoryMessenger) ModifyMessage(channelID string, timestamp string, text string) (err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if _, err = mm.lookup(channelID, timestamp); err != nil {
+		return err
+	}
+	mm.messages[channelID][timestamp] = text
+	mm.record("ModifyMessage", channelID, timestamp, text)
+	return nil
+}
+
+func (mm *MemoryMessenger) DeleteMessage(channelID string, timestamp string) (err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	text, err := mm.lookup(channelID, timestamp)
+	if err != nil {
+		return err
+	}
+	delete(mm.messages[channelID], timestamp)
+	delete(mm.pins[channelID], timestamp)
+	mm.record("DeleteMessage", channelID, timestamp, text)
+	return nil
+}
+
+func (mm *MemoryMessenger) UploadFile(channelID string, filePath string, title string) (err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.record("UploadFile", channelID, "", filePath)
+	return nil
+}
+
+func (mm *MemoryMessenger) GetUserName(userID string) (user string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if name, ok := mm.Users[userID]; ok {
+		return name
+	}
+	return "<@" + userID + ">"
+}
@@ -154,7 +154,7 @@ func (sc *slackClient) PinMessage(channelID string, timestamp string) (err error
 	return err
 }
 
-func (sc *slackClient) CommandStreamer(command string, outputType string, channelID string, timeout int) (output []string, err error) {
+func CommandStreamer(m Messenger, command string, outputType string, channelID string, timeout int) (output []string, err error) {
 	// timeout in seconds
 	// outputType is either "out" or "err"
 	cmd := exec.Command("bash", "-c", command)
@@ -166,13 +166,13 @@ func (sc *slackClient) CommandStreamer(command string, outputType string, channe
 		stdpipe, err = cmd.StderrPipe()
 	} else {
 		errMsg := "command streamer needs a correct output type"
-		sc.logger.WithField("err", err).Error(errMsg)
+		log.WithField("err", err).Error(errMsg)
 		return output, errors.New(errMsg)
 	}
 
 	if err != nil {
 		errMsg := "cannot create standard pipe"
-		sc.logger.WithField("err", err).Error(errMsg)
+		log.WithField("err", err).Error(errMsg)
 		return output, errors.New(errMsg)
 	}
 
@@ -181,12 +181,12 @@ func (sc *slackClient) CommandStreamer(command string, outputType string, channe
 		for scanner.Scan() {
 			outputLine := scanner.Text()
 			go func() {
-				ts, err := PostMessage(channelID, outputLine)
+				ts, err := m.PostMessage(channelID, outputLine)
 				if err == nil {
 					time.Sleep(time.Duration(timeout) * time.Second)
-					sc.DeleteMessage(channelID, ts)
+					m.DeleteMessage(channelID, ts)
 				} else {
-					sc.logger.WithFields(log.Fields{
+					log.WithFields(log.Fields{
 						"err":     err,
 						"command": command,
 						"line":    outputLine,
@@ -200,7 +200,7 @@ func (sc *slackClient) CommandStreamer(command string, outputType string, channe
 	err = cmd.Start()
 	if err != nil {
 		errMsg := "error starting Cmd"
-		sc.logger.WithFields(log.Fields{
+		log.WithFields(log.Fields{
 			"err":     err,
 			"command": command,
 		}).Error(errMsg)
@@ -210,7 +210,7 @@ func (sc *slackClient) CommandStreamer(command string, outputType string, channe
 	err = cmd.Wait()
 	if err != nil {
 		errMsg := "error waiting for Cmd"
-		sc.logger.WithFields(log.Fields{
+		log.WithFields(log.Fields{
 			"err":     err,
 			"command": command,
 		}).Error(errMsg)
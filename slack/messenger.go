@@ -0,0 +1,20 @@
+package slack
+
+// Messenger is the set of chat operations the jobs and schedules rely on.
+// The Slack client satisfies it, and so does MemoryMessenger, which lets
+// jobs run without a Slack workspace.
+type Messenger interface {
+	PostMessage(channelID string, text string) (timestamp string, err error)
+	SendMessage(channel string, text string) (timestamp string, err error)
+	Message(text string) (timestamp string, err error)
+	React(timestamp string, channelID string, text string) error
+	PinMessage(channelID string, timestamp string) error
+	ListPins(channelID string) (pinnedMessages map[string]string, err error)
+	ModifyMessage(channelID string, timestamp string, text string) error
+	DeleteMessage(channelID string, timestamp string) error
+	UploadFile(channelID string, filePath string, title string) error
+	GetUserName(userID string) (user string)
+}
+
+var _ Messenger = (*slackClient)(nil)
+var _ Messenger = (*MemoryMessenger)(nil)
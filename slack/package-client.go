@@ -6,6 +6,10 @@ func CreatePackageClient(botChannel string) {
 	packageSlackClient = CreateClient("global", botChannel)
 }
 
+func PackageMessenger() Messenger {
+	return packageSlackClient
+}
+
 func EventProcessor() {
 	packageSlackClient.EventProcessor()
 }
@@ -50,10 +54,6 @@ func PinMessage(channelID string, timestamp string) error {
 	return packageSlackClient.PinMessage(channelID, timestamp)
 }
 
-func CommandStreamer(command string, outputType string, channelID string, timeout int) (output []string, err error) {
-	return packageSlackClient.CommandStreamer(command, outputType, channelID, timeout)
-}
-
 func GetUserName(userID string) (user string) {
-	return packageSlackClient.getUserName(userID)
+	return packageSlackClient.GetUserName(userID)
 }